@@ -0,0 +1,147 @@
+package bench
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/brcgo/src/pipelines"
+)
+
+// Shell command dropping the page cache on Linux
+const DROP_CACHES_HINT = "sync; echo 3 | sudo tee /proc/sys/vm/drop_caches"
+
+type Options struct {
+	Runs       int  // measured runs per pipeline
+	Warmup     int  // unmeasured runs before the measured ones
+	DropCaches bool // drop the page cache before every run, see DropCaches
+	Pipeline   pipelines.Options
+}
+
+// Run is a single measured run
+type Run struct {
+	Wall       time.Duration
+	Lines      int64
+	Bytes      int64
+	Mallocs    uint64
+	AllocBytes uint64
+	NumGC      uint32
+	GCPause    time.Duration
+}
+
+// Report summarizes the measured runs of one pipeline
+type Report struct {
+	Mode        string        `json:"mode"`
+	Runs        int           `json:"runs"`
+	Min         time.Duration `json:"min_ns"`
+	Median      time.Duration `json:"median_ns"`
+	P95         time.Duration `json:"p95_ns"`
+	MBPerSec    float64       `json:"mb_per_sec"`
+	LinesPerSec float64       `json:"lines_per_sec"`
+	Lines       int64         `json:"lines"`
+	Bytes       int64         `json:"bytes"`
+	Mallocs     uint64        `json:"mallocs_per_run"`
+	AllocBytes  uint64        `json:"alloc_bytes_per_run"`
+	NumGC       uint32        `json:"gc_per_run"`
+	GCPause     time.Duration `json:"gc_pause_ns_per_run"`
+}
+
+// Bench runs p opts.Warmup + opts.Runs times against src and summarizes the measured runs
+func Bench(ctx context.Context, mode string, p pipelines.Pipeline, src pipelines.Source, opts Options) (*Report, error) {
+	if opts.Runs <= 0 {
+		opts.Runs = 1
+	}
+
+	for i := 0; i < opts.Warmup; i++ {
+		if _, err := measure(ctx, p, src, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	runs := make([]Run, 0, opts.Runs)
+	for i := 0; i < opts.Runs; i++ {
+		run, err := measure(ctx, p, src, opts)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return summarize(mode, runs), nil
+}
+
+func measure(ctx context.Context, p pipelines.Pipeline, src pipelines.Source, opts Options) (Run, error) {
+	if opts.DropCaches {
+		if err := DropCaches(); err != nil {
+			return Run{}, err
+		}
+	}
+
+	// start every run from a collected heap so runs do not pay for each other
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	start := time.Now()
+	res, err := p.Run(ctx, src, opts.Pipeline)
+	wall := time.Since(start)
+	if err != nil {
+		return Run{}, err
+	}
+
+	runtime.ReadMemStats(&after)
+	return Run{
+		Wall:       wall,
+		Lines:      res.Lines,
+		Bytes:      res.Bytes,
+		Mallocs:    after.Mallocs - before.Mallocs,
+		AllocBytes: after.TotalAlloc - before.TotalAlloc,
+		NumGC:      after.NumGC - before.NumGC,
+		GCPause:    time.Duration(after.PauseTotalNs - before.PauseTotalNs),
+	}, nil
+}
+
+func summarize(mode string, runs []Run) *Report {
+	n := len(runs)
+	walls := make([]time.Duration, n)
+	var mallocs, allocBytes uint64
+	var numGC uint32
+	var gcPause time.Duration
+	for i, r := range runs {
+		walls[i] = r.Wall
+		mallocs += r.Mallocs
+		allocBytes += r.AllocBytes
+		numGC += r.NumGC
+		gcPause += r.GCPause
+	}
+	sort.Slice(walls, func(i, j int) bool { return walls[i] < walls[j] })
+
+	report := &Report{
+		Mode:       mode,
+		Runs:       n,
+		Min:        walls[0],
+		Median:     percentile(walls, 50),
+		P95:        percentile(walls, 95),
+		Lines:      runs[0].Lines,
+		Bytes:      runs[0].Bytes,
+		Mallocs:    mallocs / uint64(n),
+		AllocBytes: allocBytes / uint64(n),
+		NumGC:      numGC / uint32(n),
+		GCPause:    gcPause / time.Duration(n),
+	}
+	if secs := report.Median.Seconds(); secs > 0 {
+		report.MBPerSec = float64(report.Bytes) / (1024 * 1024) / secs
+		report.LinesPerSec = float64(report.Lines) / secs
+	}
+	return report
+}
+
+// percentile of sorted durations using the nearest rank method
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
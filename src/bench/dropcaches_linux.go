@@ -0,0 +1,16 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// DropCaches flushes dirty pages and drops the page cache so runs read from disk, requires root
+func DropCaches() error {
+	syscall.Sync()
+	if err := os.WriteFile("/proc/sys/vm/drop_caches", []byte("3"), 0); err != nil {
+		return fmt.Errorf("dropping page cache: %w, run as root or drop it manually: %s", err, DROP_CACHES_HINT)
+	}
+	return nil
+}
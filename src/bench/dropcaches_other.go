@@ -0,0 +1,10 @@
+//go:build !linux
+
+package bench
+
+import "fmt"
+
+// DropCaches is only supported on Linux
+func DropCaches() error {
+	return fmt.Errorf("dropping page cache is not supported on this platform, on Linux: %s", DROP_CACHES_HINT)
+}
@@ -0,0 +1,28 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+func WriteTable(w io.Writer, reports []*Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Mode\tRuns\tMin\tMedian\tP95\tMB/s\tLines/s\tAllocs\tAlloc MB\tGCs\tGC pause\t")
+	for _, r := range reports {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%.1f\t%.0f\t%d\t%.1f\t%d\t%s\t\n",
+			r.Mode, r.Runs,
+			r.Min.Round(time.Microsecond), r.Median.Round(time.Microsecond), r.P95.Round(time.Microsecond),
+			r.MBPerSec, r.LinesPerSec,
+			r.Mallocs, float64(r.AllocBytes)/(1024*1024), r.NumGC, r.GCPause.Round(time.Microsecond))
+	}
+	return tw.Flush()
+}
+
+func WriteJSON(w io.Writer, reports []*Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
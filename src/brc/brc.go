@@ -0,0 +1,62 @@
+// Package brc aggregates weather station measurements of the form
+// <station name>;<temperature> into per-station min/mean/max.
+//
+// It is the importable entry point to the engines in pipelines, callers
+// should depend on this package rather than on pipelines or domain.
+//
+// When ctx is cancelled during a run, the functions return the partial
+// result of the input read so far, marked Partial, together with the error of ctx.
+package brc
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/pipelines"
+)
+
+// Result of an aggregation, temperatures in tenths of a degree
+type Result = domain.Result
+
+// Station holds the aggregate of a single station, temperatures in tenths of a degree
+type Station = domain.StationDataInt
+
+type Options struct {
+	// Workers is the number of chunks or ranges parsed concurrently, defaults to the number of CPUs
+	Workers int
+	// ChunkSize is the number of bytes read per chunk, defaults to 1 MB
+	ChunkSize int
+}
+
+func (o Options) pipelineOptions() pipelines.Options {
+	return pipelines.Options{
+		Workers:    o.Workers,
+		BufferSize: o.ChunkSize,
+	}
+}
+
+// Process aggregates the first size bytes of r, reading newline aligned ranges of it concurrently
+func Process(ctx context.Context, r io.ReaderAt, size int64, opts Options) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return pipelines.ProcessReaderAt(ctx, r, size, opts.pipelineOptions())
+}
+
+// ProcessFile aggregates file, memory mapping it when possible
+func ProcessFile(ctx context.Context, file *os.File, opts Options) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return pipelines.ProcessFile(ctx, file, opts.pipelineOptions())
+}
+
+// ProcessStream aggregates r until EOF
+func ProcessStream(ctx context.Context, r io.Reader, opts Options) (*Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return pipelines.ProcessBytes(ctx, r, opts.pipelineOptions())
+}
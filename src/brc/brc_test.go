@@ -0,0 +1,128 @@
+package brc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+const input = `Hamburg;12.0
+Bulawayo;8.9
+Palembang;38.8
+Hamburg;34.2
+St. John's;15.2
+Bulawayo;-9.5
+Hamburg;-3.4
+`
+
+func TestProcess(t *testing.T) {
+
+	t.Run("ProcessStream", func(t *testing.T) {
+		res, err := ProcessStream(context.Background(), strings.NewReader(input), Options{})
+		AssertTrue(t, err == nil)
+		AssertEqual(t, res.Lines, int64(7))
+		AssertEqual(t, res.NoOfStations(), 4)
+
+		hamburg := res.Stations["Hamburg"]
+		AssertEqual(t, hamburg.Min, -34)
+		AssertEqual(t, hamburg.Max, 342)
+		AssertEqual(t, hamburg.Sum, 428)
+		AssertEqual(t, hamburg.Count, 3)
+	})
+
+	t.Run("Process with small chunks", func(t *testing.T) {
+		r := strings.NewReader(input)
+		res, err := Process(context.Background(), r, r.Size(), Options{Workers: 3, ChunkSize: 16})
+		AssertTrue(t, err == nil)
+		AssertEqual(t, res.Lines, int64(7))
+		AssertEqual(t, res.Bytes, int64(len(input)))
+		AssertEqual(t, res.Stations["Bulawayo"].Min, -95)
+		AssertEqual(t, res.Stations["St. John's"].Count, 1)
+	})
+
+	t.Run("Process with more workers than lines", func(t *testing.T) {
+		r := strings.NewReader(input)
+		res, err := Process(context.Background(), r, r.Size(), Options{Workers: 64, ChunkSize: 4})
+		AssertTrue(t, err == nil)
+		AssertEqual(t, res.Lines, int64(7))
+		AssertEqual(t, res.Stations["Hamburg"].Sum, 428)
+	})
+
+	t.Run("Rejected lines are reported with their position", func(t *testing.T) {
+		bad := input + "Oslo\nOslo;1.23\n"
+		r := strings.NewReader(bad)
+		res, err := Process(context.Background(), r, r.Size(), Options{Workers: 3, ChunkSize: 16})
+		AssertTrue(t, err == nil)
+		stream, err := ProcessStream(context.Background(), strings.NewReader(bad), Options{Workers: 3, ChunkSize: 16})
+		AssertTrue(t, err == nil)
+
+		for _, res := range []*Result{res, stream} {
+			AssertEqual(t, res.Lines, int64(9))
+			AssertEqual(t, res.Errors, int64(2))
+			AssertEqual(t, len(res.ParseErrors), 2)
+			AssertEqual(t, res.ParseErrors[0].Line, int64(8))
+			AssertEqual(t, res.ParseErrors[0].Offset, int64(len(input)))
+			AssertEqual(t, res.ParseErrors[1].Line, int64(9))
+			AssertEqual(t, res.ParseErrors[1].Text, "Oslo;1.23")
+		}
+	})
+
+	t.Run("ProcessFile", func(t *testing.T) {
+		fname := filepath.Join(t.TempDir(), "measurements.txt")
+		if err := os.WriteFile(fname, []byte(input), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		res, err := ProcessFile(context.Background(), file, Options{Workers: 3})
+		AssertTrue(t, err == nil)
+		AssertEqual(t, res.Lines, int64(7))
+		AssertEqual(t, res.Stations["Palembang"].Max, 388)
+	})
+
+	t.Run("Missing trailing newline", func(t *testing.T) {
+		res, err := ProcessStream(context.Background(), strings.NewReader("A;1.0\nB;2.0"), Options{})
+		AssertTrue(t, err == nil)
+		AssertEqual(t, res.NoOfStations(), 2)
+	})
+
+	t.Run("Cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := ProcessStream(ctx, strings.NewReader(input), Options{})
+		AssertTrue(t, err != nil)
+	})
+
+	t.Run("Cancelled during a run", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		r := &cancelReader{Reader: strings.NewReader(input), cancel: cancel}
+		res, err := ProcessStream(ctx, r, Options{Workers: 2, ChunkSize: 30})
+		AssertTrue(t, errors.Is(err, context.Canceled))
+		AssertTrue(t, res.Partial)
+		AssertEqual(t, res.Offset, int64(len("Hamburg;12.0\nBulawayo;8.9\n")))
+		AssertEqual(t, res.Lines, int64(2))
+		AssertEqual(t, res.Stations["Hamburg"].Count, 1)
+	})
+}
+
+// cancelReader cancels its context after the first read
+type cancelReader struct {
+	io.Reader
+	cancel context.CancelFunc
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	defer r.cancel()
+	return r.Reader.Read(p)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/brcgo/src/bench"
+	"github.com/brcgo/src/pipelines"
+)
+
+func benchCmd(args []string) int {
+	fs := newFlagSet("bench", "-f <file_name> [-modes a,b,...] [-n runs] [-warmup runs] [-json] [file|glob|dir...]",
+		"Run pipelines repeatedly against a measurement file and report wall time percentiles,\nthroughput, allocations and GC pauses.")
+	files := addInputFlags(fs)
+	modes := fs.String("modes", "", "Comma separated pipelines to run, all when empty")
+	runs := fs.Int("n", 5, "Number of measured runs per pipeline")
+	warmup := fs.Int("warmup", 1, "Number of unmeasured runs per pipeline before measuring")
+	dropCaches := fs.Bool("drop-caches", false, "Drop the page cache before every run (Linux, requires root)")
+	asJSON := fs.Bool("json", false, "Write the report as JSON instead of a table")
+	pf := addPipelineFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	paths, err := files.paths(fs)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	selected, err := parseModes(*modes)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	if *runs <= 0 {
+		return usageError(fs, "Number of runs must be greater than 0")
+	}
+	if *warmup < 0 {
+		return usageError(fs, "Number of warmup runs must not be negative")
+	}
+	opts, err := pf.options()
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	if !*dropCaches {
+		log.Printf("Measuring with a warm page cache, for cold runs use -drop-caches or run between runs: %s", bench.DROP_CACHES_HINT)
+	}
+
+	benchOpts := bench.Options{
+		Runs:       *runs,
+		Warmup:     *warmup,
+		DropCaches: *dropCaches,
+		Pipeline:   opts,
+	}
+
+	sources, err := openSources(paths, true, opts)
+	if err != nil {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+	src := pipelines.MultiSource(sources...)
+
+	ctx, cancel := interruptContext(0)
+	defer cancel()
+
+	reports := make([]*bench.Report, 0, len(selected))
+	for _, mode := range selected {
+		p, _ := pipelines.Get(mode)
+		log.Printf("Benchmarking %s", mode)
+		report, err := bench.Bench(ctx, mode, p, src, benchOpts)
+		if err != nil {
+			log.Printf("%s: %s: %v", ERROR, mode, err)
+			return EXIT_ERROR
+		}
+		reports = append(reports, report)
+	}
+
+	if *asJSON {
+		err = bench.WriteJSON(os.Stdout, reports)
+	} else {
+		err = bench.WriteTable(os.Stdout, reports)
+	}
+	if err != nil {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+	return EXIT_OK
+}
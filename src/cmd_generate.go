@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+
+	"github.com/brcgo/src/util"
+)
+
+func generateCmd(args []string) int {
+	fs := newFlagSet("generate", "-f <file_name> [-r rows] [-s stations]",
+		"Create a measurement file with random stations and temperatures.")
+	fname := fs.String("f", "", "The name of the file to create")
+	no_of_rows := fs.Int("r", 100, "Number of rows to generate")
+	no_of_stations := fs.Int("s", 10, "Number of stations in generated file")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *fname == "" {
+		return usageError(fs, "Filename is required: -f <file_name>")
+	}
+	if *no_of_rows <= 0 {
+		return usageError(fs, "Number of rows must be greater than 0")
+	}
+	if *no_of_stations <= 0 {
+		return usageError(fs, "Number of stations must be greater than 0")
+	}
+	if *no_of_rows > MAX_NO_OF_ROWS {
+		*no_of_rows = MAX_NO_OF_ROWS
+	}
+
+	log.Printf("Generating file with %d rows and %d stations\n", *no_of_rows, *no_of_stations)
+	if err := util.GenerateFile(*no_of_rows, *no_of_stations, *fname); err != nil {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+	log.Printf("File generated: %s\n", *fname)
+	return EXIT_OK
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/output"
+)
+
+func mergeCmd(args []string) int {
+	fs := newFlagSet("merge", "[-dump file] <state_file>...",
+		"Merge partial results written by 'brcgo run -dump' into one result.")
+	verbose := fs.Bool("v", false, "Print per station results and the summary instead of -format")
+	dump := fs.String("dump", "", "Write the merged partial result to this file")
+	top := fs.String("top", "", "Only output the k stations ranked first by min, mean, max or count: field:k[:asc|desc], e.g. max:10")
+	mf := addMetadataFlags(fs)
+	of := addOutputFlags(fs, output.FORMAT_CHALLENGE)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "At least one state file is required")
+	}
+	if err := of.check(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+	if err := mf.load(); err != nil {
+		return usageError(fs, "%v", err)
+	}
+	var rank domain.Ranking
+	if *top != "" {
+		var err error
+		if rank, err = domain.ParseRanking(*top); err != nil {
+			return usageError(fs, "%v", err)
+		}
+	}
+
+	merged := domain.NewResult()
+	for _, fname := range fs.Args() {
+		res, err := readState(fname)
+		if err != nil {
+			log.Printf("%s: %s: %v", ERROR, fname, err)
+			return EXIT_ERROR
+		}
+		merged.Merge(res)
+	}
+
+	out := mf.apply(merged, *verbose)
+	if *top != "" {
+		out = out.Ranked(rank)
+	}
+	if *verbose {
+		domain.PrintResult(out, true)
+	} else if err := of.write(out); err != nil {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+
+	if *dump != "" {
+		if err := writeState(*dump, merged); err != nil {
+			log.Printf("%s: %v", ERROR, err)
+			return EXIT_ERROR
+		}
+	}
+	if merged.Partial {
+		log.Printf("%s: a state file holds a partial result, so does the merged result", WARNING)
+		return EXIT_PARTIAL
+	}
+	return EXIT_OK
+}
+
+func readState(fname string) (*domain.Result, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return domain.ReadState(file)
+}
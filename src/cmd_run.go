@@ -0,0 +1,264 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/output"
+	"github.com/brcgo/src/pipelines"
+	"github.com/brcgo/src/progress"
+	"github.com/jnsoft/jngo/profiling"
+)
+
+func runCmd(args []string) int {
+	fs := newFlagSet("run", "-f <file_name> [-mode mode] [flags] [file|glob|dir...]",
+		"Aggregate a measurement file with one of the pipelines: "+strings.Join(pipelines.Names(), ", "))
+	files := addInputFlags(fs)
+	verbose := fs.Bool("v", false, "Enable verbose logging")
+	mode := fs.String("mode", pipelines.MODE_BYTES, "Pipeline to run: "+strings.Join(pipelines.Names(), ", "))
+	profile := fs.Bool("prof", false, "Write a CPU profile to "+PROF_FNAME)
+	dump := fs.String("dump", "", "Write the partial result to this file for brcgo merge")
+	quiet := fs.Bool("quiet", false, "Do not report progress, it is only reported when stderr is a terminal")
+	stats := fs.Bool("stats", false, "Compute the standard deviation, variance, median, p90, p95, p99 and mode of every station")
+	top := fs.String("top", "", "Only output the k stations ranked first by min, mean, max or count: field:k[:asc|desc], e.g. max:10")
+	window := fs.String("window", "", "Aggregate timestamped lines <station>;<timestamp>;<temperature> into hour, day or month windows, one result per window, read sequentially without -mode")
+	perFile := fs.Bool("per-file", false, "Output the result of every file instead of their merged result, with the file name")
+	timeout := fs.Duration("timeout", 0, "Stop after this long and print the partial result, e.g. 30s (0 disables)")
+	pf := addPipelineFlags(fs)
+	rf := addRejectFlags(fs)
+	ff := addFilterFlags(fs)
+	mf := addMetadataFlags(fs)
+	of := addOutputFlags(fs, "")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	paths, err := files.paths(fs)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	p, ok := pipelines.Get(*mode)
+	if !ok {
+		return usageError(fs, "Unknown mode %q, expected one of: %s", *mode, strings.Join(pipelines.Names(), ", "))
+	}
+	var rank domain.Ranking
+	if *top != "" {
+		var err error
+		if rank, err = domain.ParseRanking(*top); err != nil {
+			return usageError(fs, "%v", err)
+		}
+		if !of.enabled() {
+			*of.format = output.FORMAT_CHALLENGE // the ranking is the output
+		}
+	}
+	if *window != "" {
+		if _, err := domain.ParseWindow(*window); err != nil {
+			return usageError(fs, "%v", err)
+		}
+		if !of.enabled() {
+			*of.format = output.FORMAT_CHALLENGE // the windows are the output
+		}
+		if *perFile {
+			return usageError(fs, "-per-file can not be combined with -window")
+		}
+		if isSet(fs, "mode") {
+			return usageError(fs, "-mode can not be combined with -window")
+		}
+		if !output.SupportsSets(*of.format) {
+			return usageError(fs, "Format %s can not be combined with -window", *of.format)
+		}
+		if *mf.group != "" {
+			return usageError(fs, "-group can not be combined with -window")
+		}
+	}
+	if *perFile {
+		if !of.enabled() {
+			*of.format = output.FORMAT_CHALLENGE // the files are the output
+		}
+		if !output.SupportsSets(*of.format) {
+			return usageError(fs, "Format %s can not be combined with -per-file", *of.format)
+		}
+	}
+	opts, err := pf.options()
+	opts.Stats = *stats
+	if err == nil {
+		err = rf.apply(&opts)
+	}
+	if err == nil {
+		err = ff.apply(&opts)
+	}
+	if err == nil {
+		err = mf.load()
+	}
+	if err == nil {
+		err = of.check()
+	}
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	if *rf.quarantine != "" {
+		quarantine, err := os.Create(*rf.quarantine)
+		if err != nil {
+			log.Printf("%s: %v", ERROR, err)
+			return EXIT_ERROR
+		}
+		defer quarantine.Close()
+		opts.Quarantine = quarantine
+	}
+
+	if *verbose {
+		log.Println("Verbose mode enabled")
+		log.Printf("Using %d workers, %d parser workers, %d aggregator workers, buffer size %d",
+			opts.Workers, opts.ParserWorkers, opts.AggregatorWorkers, opts.BufferSize)
+	}
+	if len(paths) == 1 {
+		log.Printf("Using file %s", paths[0])
+	} else {
+		log.Printf("Using %d files", len(paths))
+	}
+	if *window != "" {
+		log.Printf("Using %s windows", *window)
+	} else {
+		log.Printf("Using mode %s", *mode)
+	}
+	if *verbose {
+		log.Printf("Malformed lines: %s", opts.OnReject)
+	}
+
+	// SIGINT, SIGTERM and -timeout stop the run with a partial result
+	ctx, cancel := interruptContext(*timeout)
+	defer cancel()
+
+	sources, err := openSources(paths, false, opts)
+	if err != nil {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+	var reporter *progress.Reporter
+	if !*quiet && progress.IsTerminal(os.Stderr) {
+		size, _ := pipelines.MultiSource(sources...).Size()
+		reporter = progress.Start(os.Stderr, size)
+		opts.Progress = reporter.Counters
+	}
+
+	var res *domain.Result
+	var windows *domain.WindowedResult
+	var fileResults []pipelines.FileResult
+	run := func() (interface{}, error) {
+		switch {
+		case *window != "":
+			if windows, err = pipelines.RunWindowed(ctx, pipelines.MultiSource(sources...), *window, opts); windows != nil {
+				res = windows.Total
+			}
+		case len(sources) == 1:
+			res, err = p.Run(ctx, sources[0], opts)
+		default:
+			res, fileResults, err = pipelines.RunFiles(ctx, p, sources, opts)
+		}
+		return res, err
+	}
+	if *profile {
+		profiling.ProfileFunction(*mode, PROF_FNAME, run)
+	} else {
+		run()
+	}
+	if reporter != nil {
+		reporter.Stop()
+	}
+	if err != nil && (res == nil || !res.Partial) {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+	if *perFile || res.Partial {
+		logFiles(fileResults, *perFile)
+	}
+	if res.Partial && !res.Merged {
+		log.Printf("%s: %v, the result is partial up to byte offset %d", WARNING, err, res.Offset)
+	} else if res.Partial {
+		log.Printf("%s: %v, the result is partial", WARNING, err)
+	}
+	if fileResults == nil {
+		fileResults = []pipelines.FileResult{{Name: sources[0].Name(), Result: res}}
+	}
+
+	out := mf.apply(res, *verbose)
+	if *top != "" {
+		out = out.Ranked(rank)
+		if windows != nil {
+			windows = windows.Ranked(rank)
+		}
+	}
+	if *perFile {
+		sets := output.Sets{Kind: output.SETS_FILES, Total: res}
+		for _, f := range fileResults {
+			if f.Result == nil {
+				continue
+			}
+			fileOut := mf.grouped(f.Result)
+			if *top != "" {
+				fileOut = fileOut.Ranked(rank)
+			}
+			sets.Labels = append(sets.Labels, f.Name)
+			sets.Results = append(sets.Results, fileOut)
+		}
+		if err := of.writeSets(sets); err != nil {
+			log.Printf("%s: %v", ERROR, err)
+			return EXIT_ERROR
+		}
+		log.Println(res.Summary())
+	} else if windows != nil {
+		if err := of.writeWindows(windows); err != nil {
+			log.Printf("%s: %v", ERROR, err)
+			return EXIT_ERROR
+		}
+		if windows.Untimed > 0 {
+			log.Printf("%s: %d lines without a timestamp are in no window", WARNING, windows.Untimed)
+		}
+		log.Println(res.Summary())
+	} else if of.enabled() {
+		if err := of.write(out); err != nil {
+			log.Printf("%s: %v", ERROR, err)
+			return EXIT_ERROR
+		}
+		log.Println(res.Summary())
+	} else {
+		domain.PrintResult(out, *verbose || *stats)
+	}
+
+	if *dump != "" {
+		if err := writeState(*dump, res); err != nil {
+			log.Printf("%s: %v", ERROR, err)
+			return EXIT_ERROR
+		}
+	}
+	if res.Partial {
+		return EXIT_PARTIAL
+	}
+	return EXIT_OK
+}
+
+// logFiles logs the result of every file, only those that are partial unless all is set
+func logFiles(files []pipelines.FileResult, all bool) {
+	for _, f := range files {
+		switch {
+		case f.Result == nil:
+			log.Printf("%s: not read", f.Name)
+		case all || f.Result.Partial:
+			log.Printf("%s: %s", f.Name, f.Result.Summary())
+		}
+	}
+}
+
+func writeState(fname string, res *domain.Result) error {
+	file, err := os.Create(fname)
+	if err != nil {
+		return err
+	}
+	if err := domain.WriteState(file, res); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
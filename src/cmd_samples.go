@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/brcgo/src/verify"
+)
+
+func samplesCmd(args []string) int {
+	fs := newFlagSet("samples", "-dir <dir> [-modes a,b,...]",
+		"Run pipelines on every measurements-*.txt of a directory and compare their output\nbyte for byte with the measurements-*.out next to it.")
+	dir := fs.String("dir", "", "Directory with measurements-*.txt and measurements-*.out pairs")
+	modes := fs.String("modes", "", "Comma separated pipelines to check, all when empty")
+	pf := addPipelineFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if *dir == "" {
+		return usageError(fs, "A sample directory is required")
+	}
+	selected, err := parseModes(*modes)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	opts, err := pf.options()
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	samples, err := verify.FindSamples(*dir)
+	if err != nil {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+
+	ctx, cancel := interruptContext(0)
+	defer cancel()
+
+	reports, err := verify.RunSamples(ctx, samples, selected, opts)
+	if err != nil {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+
+	failed := 0
+	for _, r := range reports {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("%-30s %-12s ERROR %v\n", r.Sample, r.Mode, r.Err)
+			failed++
+		case r.Diff != "":
+			fmt.Printf("%-30s %-12s FAIL %s\n", r.Sample, r.Mode, r.Diff)
+			failed++
+		default:
+			fmt.Printf("%-30s %-12s OK\n", r.Sample, r.Mode)
+		}
+	}
+	log.Printf("%d samples, %d pipelines, %d failed", len(samples), len(selected), failed)
+	if failed > 0 {
+		return EXIT_MISMATCH
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/brcgo/src/pipelines"
+	"github.com/brcgo/src/verify"
+)
+
+func verifyCmd(args []string) int {
+	fs := newFlagSet("verify", "-f <file_name> [-modes a,b,...] [-max n] [file|glob|dir...]",
+		"Run pipelines on a measurement file and compare their results per station with a reference implementation.")
+	files := addInputFlags(fs)
+	modes := fs.String("modes", "", "Comma separated pipelines to check, all when empty")
+	maxMismatches := fs.Int("max", 10, "Maximum number of mismatches to print per pipeline, 0 for all")
+	pf := addPipelineFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	paths, err := files.paths(fs)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	selected, err := parseModes(*modes)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	opts, err := pf.options()
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+
+	sources, err := openSources(paths, true, opts)
+	if err != nil {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+	src := pipelines.MultiSource(sources...)
+
+	ctx, cancel := interruptContext(0)
+	defer cancel()
+
+	reports, err := verify.Verify(ctx, src, selected, opts)
+	if err != nil {
+		log.Printf("%s: %v", ERROR, err)
+		return EXIT_ERROR
+	}
+
+	code := EXIT_OK
+	for _, r := range reports {
+		switch {
+		case r.Err != nil:
+			fmt.Printf("%-12s ERROR %v\n", r.Mode, r.Err)
+			code = EXIT_MISMATCH
+		case len(r.Mismatches) > 0:
+			fmt.Printf("%-12s MISMATCH %d\n", r.Mode, len(r.Mismatches))
+			for i, m := range r.Mismatches {
+				if *maxMismatches > 0 && i == *maxMismatches {
+					fmt.Printf("  ... %d more\n", len(r.Mismatches)-i)
+					break
+				}
+				fmt.Printf("  %s\n", m)
+			}
+			code = EXIT_MISMATCH
+		default:
+			fmt.Printf("%-12s OK\n", r.Mode)
+		}
+	}
+	return code
+}
@@ -1,14 +1,17 @@
 package domain
 
-func Aggregate(data StringFloat, hashmap *map[string]*StationData) {
+// Aggregate adds data to its station, newStats returns the extended statistics of a new station or nil
+func Aggregate(data StringFloat, hashmap *map[string]*StationData, newStats func() *Stats) {
 	aggregated, exists := (*hashmap)[data.Key]
 	if !exists {
-		(*hashmap)[data.Key] = &StationData{
+		aggregated = &StationData{
 			Min:   data.Value,
 			Max:   data.Value,
 			Sum:   data.Value,
 			Count: 1,
+			Stats: newStats(),
 		}
+		(*hashmap)[data.Key] = aggregated
 	} else {
 		if data.Value < aggregated.Min {
 			aggregated.Min = data.Value
@@ -18,4 +21,7 @@ func Aggregate(data StringFloat, hashmap *map[string]*StationData) {
 		aggregated.Sum += data.Value
 		aggregated.Count++
 	}
+	if aggregated.Stats != nil {
+		aggregated.Stats.AddFloat(data.Value)
+	}
 }
@@ -8,22 +8,50 @@ import (
 )
 
 type ByteResult struct {
-	stations map[int]*ByteStation
-	inputs   int
-	mu       sync.Mutex
+	stations    *StationTable
+	inputs      int
+	mergedStats TableStats // table statistics of the results merged into this one
+	errors      ErrorLog
+	stats       bool // track extended statistics per station
+	filter      *Filter
+	filtered    int64 // lines filtered out by Keep
+	mu          sync.Mutex
 }
 
 func NewByteResult() *ByteResult {
+	return NewByteResultWithHash(HASH_FNV1A)
+}
+
+func NewByteResultWithHash(hashFunc HashFunc) *ByteResult {
 	return &ByteResult{
-		stations: make(map[int]*ByteStation),
+		stations: NewStationTable(hashFunc, 0),
 		inputs:   0,
 	}
 }
 
+// EnableStats tracks the extended statistics of the stations added from now on
+func (r *ByteResult) EnableStats() {
+	r.stats = true
+}
+
+// SetFilter selects the readings that Keep lets through
+func (r *ByteResult) SetFilter(f *Filter) {
+	r.filter = f
+}
+
+// Keep reports whether reading passes the filter, counting it otherwise. Like AddLocal it does not lock.
+func (r *ByteResult) Keep(reading ByteStationReading) bool {
+	if r.filter.Match(reading.StationId, reading.Temperature) {
+		return true
+	}
+	r.filtered++
+	return false
+}
+
 func (r *ByteResult) NoOfStations() int {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	return len(r.stations)
+	return r.stations.Len()
 }
 
 func (r *ByteResult) NoOfInputs() int {
@@ -35,26 +63,37 @@ func (r *ByteResult) NoOfInputs() int {
 func (r *ByteResult) GetStations() int {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	return len(r.stations)
+	return r.stations.Len()
 }
 
-func (r *ByteResult) Add(reading ByteStationReading) {
-	key := reading.HashCodeSimple()
+// TableStats of the station table, see StationTable
+func (r *ByteResult) TableStats() TableStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tableStats()
+}
 
+func (r *ByteResult) tableStats() TableStats {
+	stats := r.stations.Stats()
+	stats.merge(r.mergedStats)
+	return stats
+}
 
-	//"{Atowrfn=6.4/33.8/64.7, Atowrfn;=-81.7/-64.2/-46.7, Enet=27.4/62.6/94.8, Enet;=-47.4/-29.2/-0.8, Iguhdbgkogbgfd=41.5/58.3/89.6, Iguhdbgkogbgfd;=-69.2/-40.4/-12.4, Isaekqjhvwdai=2.7/53.9/90.6, Isaekqjhvwdai;=-71.8/-31.8/-1.6, Llrdjlkay=63.7/82.0/97.4, Llrdjlkay;=-95.5/-69.5/-30.2, Ofhozrvb=65.2/65.2/65.2, Ofhozrvb;=-95.8/-74.7/-34.4, Sryvgwxhf=0.8/51.4/97.0, Sryvgwxhf;=-68.3/-33.7/-11.5, Uaaych=1.3/44.1/98.9, Uaaych;=-95.2/-62.0/-8.3, Wiuhlvdbwpuxd=44.8/47.2/49.7, Wiuhlvdbwpuxd;=-97.5/-68.8/-44.1, Xoxjchtgdn...+46 more"
+func (r *ByteResult) Add(reading ByteStationReading) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.AddLocal(reading)
+}
+
+// AddLocal adds without locking, for results owned by a single goroutine
+func (r *ByteResult) AddLocal(reading ByteStationReading) {
 	r.inputs++
-	station, exists := r.stations[key]
+	station, exists := r.stations.GetOrInsert(reading.StationId)
 	if !exists {
-		r.stations[key] = &ByteStation{
-			StationId: reading.StationId,
-			Min:       reading.Temperature,
-			Max:       reading.Temperature,
-			Sum:       int64(reading.Temperature),
-			Count:     1,
-		}
+		station.Min = reading.Temperature
+		station.Max = reading.Temperature
+		station.Sum = int64(reading.Temperature)
+		station.Count = 1
 	} else {
 		station.Sum += int64(reading.Temperature)
 		if station.Min > reading.Temperature {
@@ -65,17 +104,59 @@ func (r *ByteResult) Add(reading ByteStationReading) {
 		}
 		station.Count++
 	}
+	if r.stats {
+		if station.Stats == nil {
+			station.Stats = NewStats()
+		}
+		station.Stats.Add(reading.Temperature)
+	}
+}
+
+// Errors of the rejected lines
+func (r *ByteResult) Errors() *ErrorLog {
+	return &r.errors
+}
+
+// Merge combines the stations of another partial result into r
+func (r *ByteResult) Merge(other *ByteResult) {
+	if r == other {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	other.mu.Lock()
+	defer other.mu.Unlock()
+
+	for _, o := range other.stations.Stations() {
+		station, exists := r.stations.GetOrInsert(o.StationId)
+		if !exists {
+			station.Min = o.Min
+			station.Max = o.Max
+			station.Sum = o.Sum
+			station.Count = o.Count
+			station.Stats = o.Stats.Clone()
+		} else {
+			station.Sum += o.Sum
+			station.Min = min(station.Min, o.Min)
+			station.Max = max(station.Max, o.Max)
+			station.Count += o.Count
+			mergeStats(&station.Stats, o.Stats)
+		}
+	}
+	r.inputs += other.inputs
+	r.filtered += other.filtered
+	merged := other.tableStats()
+	merged.NameBytes, merged.ArenaBytes = 0, 0 // names of other are released with it
+	r.mergedStats.merge(merged)
+	r.errors.Merge(&other.errors)
 }
 
 func (r *ByteResult) String() string {
-	return fmt.Sprintf("Processed %d stations.", len(r.stations))
+	return fmt.Sprintf("Processed %d stations.", r.stations.Len())
 }
 
 func (r *ByteResult) GetSortedResults() string {
-	stations := make([]*ByteStation, 0, len(r.stations))
-	for _, v := range r.stations {
-		stations = append(stations, v)
-	}
+	stations := r.stations.Stations()
 	sort.Slice(stations, func(i, j int) bool {
 		return stations[i].StationName() < stations[j].StationName()
 	})
@@ -96,10 +177,7 @@ func (r *ByteResult) PrintResults() {
 	fmt.Println("StationId\tMin\tMax\tSum\tCount")
 	fmt.Println("---------------------------------------")
 	// Sort by StationName
-	stations := make([]*ByteStation, 0, len(r.stations))
-	for _, v := range r.stations {
-		stations = append(stations, v)
-	}
+	stations := r.stations.Stations()
 	sort.Slice(stations, func(i, j int) bool {
 		return stations[i].StationName() < stations[j].StationName()
 	})
@@ -107,3 +185,25 @@ func (r *ByteResult) PrintResults() {
 		fmt.Println(s)
 	}
 }
+
+func (r *ByteResult) ToResult() *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := NewResult()
+	for _, s := range r.stations.Stations() {
+		res.Stations[s.StationName()] = &StationDataInt{
+			Min:   s.Min,
+			Max:   s.Max,
+			Sum:   int(s.Sum),
+			Count: s.Count,
+			Stats: s.Stats,
+		}
+	}
+	res.Errors = r.errors.Count()
+	res.ParseErrors = r.errors.Errors()
+	res.Filtered = r.filtered
+	res.Lines = int64(r.inputs) + res.Errors + res.Filtered
+	stats := r.tableStats()
+	res.Table = &stats
+	return res
+}
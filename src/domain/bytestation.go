@@ -1,13 +1,12 @@
 package domain
 
-import "fmt"
-
 type ByteStation struct {
 	StationId   []byte
 	Sum         int64
 	Min         int
 	Max         int
 	Count       int
+	Stats       *Stats // extended statistics, nil unless enabled
 	stationName string
 }
 
@@ -32,17 +31,10 @@ func (b *ByteStation) StationName() string {
 }
 
 func (b *ByteStation) String() string {
-	return fmt.Sprintf("%s=%.1f/%.1f/%.1f",
-		b.StationName(),
-		float64(b.Min)/10,
-		b.averageTemperature(),
-		float64(b.Max)/10,
-	)
+	return b.StationName() + "=" + FormatStation(b.Min, b.averageTemperature(), b.Max)
 }
 
-func (b *ByteStation) averageTemperature() float64 {
-	if b.Count > 0 {
-		return float64(b.Sum) / float64(b.Count*10)
-	}
-	return 0.0
+// averageTemperature in tenths of a degree, rounded like the challenge
+func (b *ByteStation) averageTemperature() int {
+	return MeanTenths(b.Sum, b.Count)
 }
@@ -12,55 +12,22 @@ func NewByteStationReading() ByteStationReading {
 	}
 }
 
-func NewByteStationReadingFromBytes(bs []byte) ByteStationReading {
-	lg := len(bs)
-	var ix int
-
-	// find split
-	for ix = 0; ix < lg; ix++ {
-		if bs[ix] == ASCII_SEMICOLON {
-			break
-		}
-	}
-
-	// check sign
-	neg := 0
-	if bs[ix+1] == ASCII_MINUS {
-		neg = 1
-	}
-
-	// read number
-	fac := 1
-	n := 0
-	for i := lg - 1; i > ix+neg; i-- {
-		if bs[i] != ASCII_DOT {
-			n += fac * int(bs[i]-ASCII_ZERO)
-			fac *= 10
-		}
+// NewByteStationReadingFromBytes parses a line, StationId is a sub-slice of bs
+func NewByteStationReadingFromBytes(bs []byte) (ByteStationReading, error) {
+	name, temp, err := ParseLine(bs)
+	if err != nil {
+		return ByteStationReading{}, NewParseError(bs, err)
 	}
-	if neg == 1 {
-		n = -n
-	}
-
 	return ByteStationReading{
-		StationId:   bs[:ix],
-		Temperature: n,
-	}
+		StationId:   name,
+		Temperature: temp,
+	}, nil
 }
 
 func (r ByteStationReading) HashCode() int {
-	const prime = 16777619
-	hash := uint32(2166136261)
-	for _, b := range r.StationId {
-		hash = (hash ^ uint32(b)) * prime
-	}
-	return int(hash)
+	return int(HashFNV1a(r.StationId))
 }
 
 func (r ByteStationReading) HashCodeSimple() int {
-	hash := 17
-	for _, b := range r.StationId {
-		hash = hash*31 + int(b)
-	}
-	return hash
+	return int(HashSimple(r.StationId))
 }
@@ -0,0 +1,96 @@
+package domain
+
+import (
+	"sort"
+	"sync"
+)
+
+// Number of parse errors kept by an ErrorLog for reporting, all are counted
+const MAX_LOGGED_ERRORS = 10
+
+// ErrorLog counts parse errors and keeps the first MAX_LOGGED_ERRORS, safe for concurrent use.
+// Every error is passed to the reject policy when it is added.
+type ErrorLog struct {
+	mu     sync.Mutex
+	count  int64
+	errors []*ParseError
+	policy *RejectPolicy
+}
+
+func NewErrorLog(policy *RejectPolicy) *ErrorLog {
+	return &ErrorLog{policy: policy}
+}
+
+// SetPolicy applies policy to the errors added from now on
+func (l *ErrorLog) SetPolicy(policy *RejectPolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.policy = policy
+}
+
+func (l *ErrorLog) Add(err *ParseError) {
+	l.mu.Lock()
+	policy := l.policy
+	l.mu.Unlock()
+	if policy != nil {
+		policy.reject(err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count++
+	if len(l.errors) < MAX_LOGGED_ERRORS {
+		l.errors = append(l.errors, err)
+	}
+}
+
+func (l *ErrorLog) Count() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.count
+}
+
+// Errors kept, ordered by line and offset
+func (l *ErrorLog) Errors() []*ParseError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	errs := append([]*ParseError(nil), l.errors...)
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Offset < errs[j].Offset
+	})
+	return errs
+}
+
+// ShiftLines adds delta to the known line numbers, for errors found in a range with relative line numbers
+func (l *ErrorLog) ShiftLines(delta int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, err := range l.errors {
+		if err.Line > 0 {
+			err.Line += delta
+		}
+	}
+}
+
+// Merge adds the errors of other
+func (l *ErrorLog) Merge(other *ErrorLog) {
+	if l == other {
+		return
+	}
+	other.mu.Lock()
+	count, errs := other.count, other.errors
+	other.mu.Unlock()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count += count
+	for _, err := range errs {
+		if len(l.errors) >= MAX_LOGGED_ERRORS {
+			break
+		}
+		l.errors = append(l.errors, err)
+	}
+}
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// ErrFiltered is returned by parsers for lines that are filtered out, they are not rejected
+var ErrFiltered = errors.New("filtered out")
+
+// Filter selects the lines that are aggregated by station name and temperature, the others are counted
+// as filtered. A line is kept when it passes every condition that is set. A nil Filter keeps all lines.
+type Filter struct {
+	Include        map[string]bool // only these stations, nil for all
+	Exclude        map[string]bool // not these stations
+	Prefixes       []string        // only stations starting with one of these, nil for all
+	Pattern        *regexp.Regexp  // only stations matching it, nil for all
+	MinTemperature int             // only temperatures in MinTemperature..MaxTemperature, in tenths
+	MaxTemperature int
+
+	filtered atomic.Int64
+}
+
+// NewFilter keeps all lines until conditions are set
+func NewFilter() *Filter {
+	return &Filter{MinTemperature: MIN_TEMPERATURE, MaxTemperature: MAX_TEMPERATURE}
+}
+
+// ForRun returns a copy of f with its own count of filtered lines
+func (f *Filter) ForRun() *Filter {
+	if f == nil {
+		return nil
+	}
+	return &Filter{
+		Include:        f.Include,
+		Exclude:        f.Exclude,
+		Prefixes:       f.Prefixes,
+		Pattern:        f.Pattern,
+		MinTemperature: f.MinTemperature,
+		MaxTemperature: f.MaxTemperature,
+	}
+}
+
+// Keep reports whether a line of station name with temperature t in tenths is aggregated.
+// It is safe for concurrent use and counts the lines it filters out.
+func (f *Filter) Keep(name string, t int) bool {
+	if f == nil || match(f, name, t) {
+		return true
+	}
+	f.filtered.Add(1)
+	return false
+}
+
+// KeepFloat is Keep for a temperature in degrees
+func (f *Filter) KeepFloat(name string, t float64) bool {
+	return f.Keep(name, int(math.Round(t*10)))
+}
+
+// Match is Keep for a name in bytes without counting, for callers counting themselves
+func (f *Filter) Match(name []byte, t int) bool {
+	return f == nil || match(f, name, t)
+}
+
+// Filtered is the number of lines Keep has filtered out
+func (f *Filter) Filtered() int64 {
+	if f == nil {
+		return 0
+	}
+	return f.filtered.Load()
+}
+
+func match[T ~string | ~[]byte](f *Filter, name T, t int) bool {
+	if t < f.MinTemperature || t > f.MaxTemperature {
+		return false
+	}
+	if f.Include != nil && !f.Include[string(name)] {
+		return false
+	}
+	if f.Exclude[string(name)] {
+		return false
+	}
+	if f.Prefixes != nil {
+		found := false
+		for _, p := range f.Prefixes {
+			if len(name) >= len(p) && string(name[:len(p)]) == p {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if f.Pattern != nil {
+		switch n := any(name).(type) {
+		case string:
+			return f.Pattern.MatchString(n)
+		case []byte:
+			return f.Pattern.Match(n)
+		}
+	}
+	return true
+}
+
+// ReadStations reads a list of station names, one per line. Empty lines and lines starting with # are skipped.
+func ReadStations(r io.Reader) (map[string]bool, error) {
+	stations := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name := strings.TrimSuffix(scanner.Text(), "\r")
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		stations[name] = true
+	}
+	return stations, scanner.Err()
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func TestFilter(t *testing.T) {
+
+	t.Run("Nil keeps all", func(t *testing.T) {
+		var f *Filter
+		AssertTrue(t, f.Keep("a", MAX_TEMPERATURE))
+		AssertTrue(t, f.Match([]byte("a"), MIN_TEMPERATURE))
+		AssertEqual(t, f.Filtered(), int64(0))
+	})
+
+	t.Run("Conditions", func(t *testing.T) {
+		f := NewFilter()
+		f.Exclude = map[string]bool{"Abc": true}
+		f.Prefixes = []string{"A", "B"}
+		f.Pattern = regexp.MustCompile("b")
+		f.MinTemperature, f.MaxTemperature = -50, 300
+
+		AssertTrue(t, f.Keep("Ab", 0))
+		AssertTrue(t, f.Match([]byte("Bb"), 300))
+		AssertTrue(t, !f.Keep("Abc", 0))  // excluded
+		AssertTrue(t, !f.Keep("Cb", 0))   // prefix
+		AssertTrue(t, !f.Keep("Ac", 0))   // pattern
+		AssertTrue(t, !f.Keep("Ab", -51)) // temperature
+		AssertTrue(t, !f.Match([]byte("Ab"), 301))
+		AssertEqual(t, f.Filtered(), int64(4))
+		AssertEqual(t, f.ForRun().Filtered(), int64(0))
+	})
+
+	t.Run("Read stations", func(t *testing.T) {
+		stations, err := ReadStations(strings.NewReader("# stations\nAbha\r\n\nHamburg\n"))
+		AssertTrue(t, err == nil)
+		AssertEqual(t, len(stations), 2)
+		f := NewFilter()
+		f.Include = stations
+		AssertTrue(t, f.Keep("Abha", 0))
+		AssertTrue(t, !f.Keep("Oslo", 0))
+	})
+}
@@ -0,0 +1,141 @@
+package domain
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Levels stations can be grouped by
+const (
+	GROUP_COUNTRY = "country"
+	GROUP_REGION  = "region"
+	UNKNOWN_GROUP = "/unknown" // group of the stations without metadata, country and region names can not contain /
+)
+
+// StationMeta is the reference data of a station
+type StationMeta struct {
+	Name    string
+	Country string
+	Region  string
+	Lat     float64
+	Lon     float64
+}
+
+// Metadata of stations by name
+type Metadata map[string]StationMeta
+
+// ReadMetadata reads name;country;region;lat;lon lines, empty lines and lines starting with # are skipped
+func ReadMetadata(r io.Reader) (Metadata, error) {
+	meta := make(Metadata)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Split(line, ";")
+		if len(parts) != 5 {
+			return nil, fmt.Errorf("line %d: expected 5 fields name;country;region;lat;lon, got %d", lineNo, len(parts))
+		}
+		if parts[0] == "" {
+			return nil, fmt.Errorf("line %d: %w", lineNo, ErrEmptyName)
+		}
+		lat, err := strconv.ParseFloat(parts[3], 64)
+		if err != nil || lat < -90 || lat > 90 {
+			return nil, fmt.Errorf("line %d: invalid latitude %q", lineNo, parts[3])
+		}
+		lon, err := strconv.ParseFloat(parts[4], 64)
+		if err != nil || lon < -180 || lon > 180 {
+			return nil, fmt.Errorf("line %d: invalid longitude %q", lineNo, parts[4])
+		}
+		if strings.Contains(parts[1], "/") || strings.Contains(parts[2], "/") {
+			return nil, fmt.Errorf("line %d: country and region can not contain /", lineNo)
+		}
+		if _, exists := meta[parts[0]]; exists {
+			return nil, fmt.Errorf("line %d: duplicate station %q", lineNo, parts[0])
+		}
+		meta[parts[0]] = StationMeta{Name: parts[0], Country: parts[1], Region: parts[2], Lat: lat, Lon: lon}
+	}
+	return meta, scanner.Err()
+}
+
+// ParseGroups parses a comma separated list of levels, outermost first, e.g. region,country
+func ParseGroups(s string) ([]string, error) {
+	levels := strings.Split(s, ",")
+	for _, level := range levels {
+		if level != GROUP_COUNTRY && level != GROUP_REGION {
+			return nil, fmt.Errorf("unknown group %q, expected %s or %s", level, GROUP_COUNTRY, GROUP_REGION)
+		}
+	}
+	return levels, nil
+}
+
+func (m StationMeta) level(level string) string {
+	if level == GROUP_REGION {
+		return m.Region
+	}
+	return m.Country
+}
+
+// Join of the stations of a result with their metadata, names sorted
+type Join struct {
+	Missing []string // stations of the result without metadata
+	Unused  []string // stations of the metadata not in the result
+}
+
+// Join matches the stations of r with meta
+func (r *Result) Join(meta Metadata) Join {
+	var join Join
+	for name := range r.Stations {
+		if _, ok := meta[name]; !ok {
+			join.Missing = append(join.Missing, name)
+		}
+	}
+	for name := range meta {
+		if _, ok := r.Stations[name]; !ok {
+			join.Unused = append(join.Unused, name)
+		}
+	}
+	sort.Strings(join.Missing)
+	sort.Strings(join.Unused)
+	return join
+}
+
+// Group returns a copy of r with the stations rolled up by the metadata levels, outermost first.
+// Every level has its groups, named by the levels up to it joined with /, e.g. Europe and
+// Europe/Germany. Stations without metadata are rolled up into UNKNOWN_GROUP.
+func (r *Result) Group(meta Metadata, levels []string) *Result {
+	res := *r
+	res.Order = nil
+	res.Stations = make(map[string]*StationDataInt)
+	add := func(key string, s *StationDataInt) {
+		group, exists := res.Stations[key]
+		if !exists {
+			group = &StationDataInt{}
+			res.Stations[key] = group
+		}
+		group.Merge(*s)
+	}
+	for name, s := range r.Stations {
+		m, ok := meta[name]
+		if !ok {
+			add(UNKNOWN_GROUP, s)
+			continue
+		}
+		key := ""
+		for i, level := range levels {
+			if i > 0 {
+				key += "/"
+			}
+			key += m.level(level)
+			add(key, s)
+		}
+	}
+	return &res
+}
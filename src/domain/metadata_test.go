@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+const metadata = `# name;country;region;lat;lon
+Hamburg;Germany;Europe;53.55;9.99
+Berlin;Germany;Europe;52.52;13.40
+Oslo;Norway;Europe;59.91;10.75
+Abha;Saudi Arabia;Asia;18.22;42.50
+`
+
+func TestMetadata(t *testing.T) {
+	meta, err := ReadMetadata(strings.NewReader(metadata))
+	AssertTrue(t, err == nil)
+	AssertEqual(t, len(meta), 4)
+	AssertEqual(t, meta["Oslo"], StationMeta{Name: "Oslo", Country: "Norway", Region: "Europe", Lat: 59.91, Lon: 10.75})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, invalid := range []string{"Oslo;Norway;Europe;59.91", "Oslo;Norway;Europe;x;10", "Oslo;Norway;Europe;91;10",
+			";Norway;Europe;59.91;10.75", "Oslo;Norway;Europe;59.91;10.75\nOslo;Norway;Europe;59.91;10.75",
+			"Oslo;Norway;Europe/North;59.91;10.75"} {
+			_, err := ReadMetadata(strings.NewReader(invalid))
+			AssertTrue(t, err != nil)
+		}
+		_, err := ParseGroups("country,city")
+		AssertTrue(t, err != nil)
+	})
+
+	res := NewResult()
+	res.Add("Hamburg", 100)
+	res.Add("Berlin", 300)
+	res.Add("Oslo", -50)
+	res.Add("Lima", 200)
+
+	t.Run("Join", func(t *testing.T) {
+		join := res.Join(meta)
+		AssertEqual(t, strings.Join(join.Missing, ","), "Lima")
+		AssertEqual(t, strings.Join(join.Unused, ","), "Abha")
+	})
+
+	t.Run("Group", func(t *testing.T) {
+		levels, err := ParseGroups("region,country")
+		AssertTrue(t, err == nil)
+		AssertEqual(t, res.Group(meta, levels).String(),
+			"{/unknown=20.0/20.0/20.0, Europe=-5.0/11.7/30.0, Europe/Germany=10.0/20.0/30.0, Europe/Norway=-5.0/-5.0/-5.0}")
+		grouped := res.Group(meta, []string{GROUP_REGION})
+		AssertEqual(t, grouped.String(), "{/unknown=20.0/20.0/20.0, Europe=-5.0/11.7/30.0}")
+		AssertEqual(t, grouped.Stations["Europe"].Count, 3)
+		AssertEqual(t, res.Stations["Hamburg"].Count, 1)
+	})
+}
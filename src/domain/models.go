@@ -1,7 +1,8 @@
 package domain
 
 import (
-	"fmt"
+	"math"
+	"time"
 )
 
 type StringFloat struct {
@@ -14,6 +15,13 @@ type StringInt struct {
 	Value int
 }
 
+// StringIntTime is a reading with its timestamp, Time is zero when the line has none
+type StringIntTime struct {
+	Key   string
+	Time  time.Time
+	Value int
+}
+
 type BytesInt struct {
 	Key   []byte
 	Value int
@@ -24,6 +32,7 @@ type StationData struct {
 	Max   float64
 	Sum   float64
 	Count int
+	Stats *Stats // extended statistics, nil unless enabled
 }
 
 type StationDataInt struct {
@@ -31,12 +40,68 @@ type StationDataInt struct {
 	Max   int
 	Sum   int
 	Count int
+	Stats *Stats // extended statistics, nil unless enabled
 }
 
+// String formats min/mean/max with the rounding of the challenge, see MeanTenths
 func (s StationData) String() string {
-	return fmt.Sprintf("%.2f/%.2f/%.2f", s.Min, s.Sum/float64(s.Count), s.Max)
+	return s.ToInt().String()
 }
 
+// String formats min/mean/max with the rounding of the challenge, see MeanTenths
 func (s StationDataInt) String() string {
-	return fmt.Sprintf("%.2f/%.2f/%.2f", float64(s.Min)/10, float64(s.Sum)/float64(s.Count*10), float64(s.Max)/10)
+	return FormatStation(s.Min, s.Mean(), s.Max)
+}
+
+// Mean in tenths of a degree, rounded like the challenge
+func (s StationDataInt) Mean() int {
+	return MeanTenths(int64(s.Sum), s.Count)
+}
+
+// ToInt converts float data to tenths of a degree
+func (s StationData) ToInt() StationDataInt {
+	return StationDataInt{
+		Min:   int(math.Round(s.Min * 10)),
+		Max:   int(math.Round(s.Max * 10)),
+		Sum:   int(math.Round(s.Sum * 10)),
+		Count: s.Count,
+		Stats: s.Stats,
+	}
+}
+
+// Merge combines the aggregate of another partial result into s
+func (s *StationData) Merge(o StationData) {
+	if s.Count == 0 {
+		*s = o
+		s.Stats = o.Stats.Clone()
+		return
+	}
+	mergeStats(&s.Stats, o.Stats)
+	s.Min = math.Min(s.Min, o.Min)
+	s.Max = math.Max(s.Max, o.Max)
+	s.Sum += o.Sum
+	s.Count += o.Count
+}
+
+// MergeStations combines the per-station aggregates of src into dst
+func MergeStations(dst, src map[string]StationData) {
+	for k, v := range src {
+		station := dst[k]
+		station.Merge(v)
+		dst[k] = station
+	}
+}
+
+// Merge combines the aggregate of another partial result into s
+func (s *StationDataInt) Merge(o StationDataInt) {
+	if s.Count == 0 {
+		*s = o
+		s.Stats = o.Stats.Clone()
+		return
+	}
+	mergeStats(&s.Stats, o.Stats)
+	s.Min = min(s.Min, o.Min)
+	s.Max = max(s.Max, o.Max)
+	s.Sum += o.Sum
+	s.Count += o.Count
 }
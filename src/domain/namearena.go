@@ -0,0 +1,37 @@
+package domain
+
+const NAME_ARENA_BLOCK_SIZE = 64 * 1024
+
+// NameArena copies station names into compact blocks, so stations do not keep
+// the much larger buffers they were parsed from alive. Not safe for concurrent use.
+type NameArena struct {
+	blocks    int
+	block     []byte
+	allocated int64
+	used      int64
+}
+
+// Intern returns a copy of name stored in the arena
+func (a *NameArena) Intern(name []byte) []byte {
+	if len(name) > cap(a.block)-len(a.block) {
+		size := max(NAME_ARENA_BLOCK_SIZE, len(name))
+		a.block = make([]byte, 0, size)
+		a.blocks++
+		a.allocated += int64(size)
+	}
+	start := len(a.block)
+	a.block = append(a.block, name...)
+	a.used += int64(len(name))
+	// cap the slice so appending to a name can not overwrite the next one
+	return a.block[start:len(a.block):len(a.block)]
+}
+
+// Allocated bytes of all blocks
+func (a *NameArena) Allocated() int64 {
+	return a.allocated
+}
+
+// Used bytes of all blocks
+func (a *NameArena) Used() int64 {
+	return a.used
+}
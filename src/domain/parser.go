@@ -1,10 +1,6 @@
 package domain
 
-import (
-	"fmt"
-	"strconv"
-	"strings"
-)
+import "time"
 
 const (
 	ASCII_SEMICOLON = 59 // ';'
@@ -14,74 +10,52 @@ const (
 )
 
 func ParseStringFloat(s string) (StringFloat, error) {
-	parts := strings.Split(s, ";")
-	if len(parts) != 2 {
-		panic(fmt.Sprintf("Invalid line: %s", s))
+	key, value, err := ParseLine(s)
+	if err != nil {
+		return StringFloat{}, NewParseError(s, err)
 	}
-	key := strings.TrimSpace(parts[0])
-	valStr := strings.TrimSpace(parts[1])
+	return StringFloat{Key: key, Value: float64(value) / 10}, nil
+}
 
-	value, err := strconv.ParseFloat(valStr, 64)
+func ParseStringInt(s string) (StringInt, error) {
+	key, value, err := ParseLine(s)
 	if err != nil {
-		panic(fmt.Sprintf(" Failed to parse float: %s", s))
+		return StringInt{}, NewParseError(s, err)
 	}
-
-	return StringFloat{Key: key, Value: value}, nil
+	return StringInt{Key: key, Value: value}, nil
 }
 
-func ParseStringInt(s string) StringInt {
-	lg := len(s)
-	var key string
-	var ix int
-	for ix = 0; ix < lg; ix++ {
-		if s[ix] == ';' {
-			break
-		}
-	}
-	neg := 0
-	if s[ix+1] == '-' {
-		neg = 1
-	}
-	fac := 1
-	n := 0
-	for i := lg - 1; i > ix+neg; i-- {
-		if s[i] != '.' {
-			n += fac * int(s[i])
-			fac *= 10
-		}
+func ParseBytesInt(bs []byte) (BytesInt, error) {
+	key, value, err := ParseLine(bs)
+	if err != nil {
+		return BytesInt{}, NewParseError(bs, err)
 	}
-
-	key = s[:ix]
-	return StringInt{Key: key, Value: n}
+	return BytesInt{Key: key, Value: value}, nil
 }
 
-func ParseBytesInt(bs []byte) BytesInt {
-	lg := len(bs)
-
-	var ix int
-	// find split
-	for ix = 0; ix < lg; ix++ {
-		if bs[ix] == ASCII_SEMICOLON {
-			break
-		}
+// ParseStringIntTime parses <station>;<timestamp>;<temperature> or <station>;<temperature>,
+// see ParseTimedLine
+func ParseStringIntTime(s string) (StringIntTime, error) {
+	key, t, value, err := ParseTimedLine(s)
+	if err != nil {
+		return StringIntTime{}, NewParseError(s, err)
 	}
-	neg := 0
-	if bs[ix+1] == ASCII_MINUS {
-		neg = 1
+	return StringIntTime{Key: key, Time: t, Value: value}, nil
+}
+
+// ParseTimedLine is ParseLine returning the time of the optional timestamp column, see ParseTimestamp.
+// The time is zero when the line has no timestamp.
+func ParseTimedLine[T ~string | ~[]byte](line T) (T, time.Time, int, error) {
+	name, timestamp, temperature, err := splitLine(line)
+	if err != nil {
+		return name, time.Time{}, 0, err
 	}
-	// read number
-	fac := 1
-	n := 0
-	for i := lg - 1; i > ix+neg; i-- {
-		if bs[i] != ASCII_DOT {
-			n += fac * int(bs[i] - ASCII_ZERO)
-			fac *= 10
+	var t time.Time
+	if timestamp != nil {
+		if t, err = ParseTimestamp(*timestamp); err != nil {
+			return name, time.Time{}, 0, err
 		}
 	}
-	if neg == 1 {
-		n = -n
-	}
-
-	key := bs[:ix]
-	return BytesInt{Key: key, Value: n}
+	temp, err := ParseTemperature(temperature)
+	return name, t, temp, err
 }
@@ -2,22 +2,28 @@ package domain
 
 import (
 	"fmt"
-	"sort"
 )
 
-func PrintResult(hashmap *map[string]*StationData, verbose bool) {
-	keys := make([]string, 0, len(*hashmap))
-	for k := range *hashmap {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
+func PrintResult(res *Result, verbose bool) {
 	if verbose {
 		fmt.Println("\n Final aggregated results:")
-		for _, k := range keys {
-			fmt.Printf("%s=%s\n", k, (*hashmap)[k].String())
+		for _, k := range res.Keys() {
+			station := res.Stations[k]
+			if station.Stats != nil {
+				fmt.Printf("%s=%s %s\n", k, station.String(), station.Stats)
+			} else {
+				fmt.Printf("%s=%s\n", k, station.String())
+			}
+		}
+	}
+	if verbose && res.Table != nil {
+		fmt.Printf("\nStation table: %s\n", res.Table)
+	}
+	if len(res.ParseErrors) > 0 {
+		fmt.Printf("\nRejected lines (first %d of %d):\n", len(res.ParseErrors), res.Errors)
+		for _, err := range res.ParseErrors {
+			fmt.Printf("  %s\n", err)
 		}
 	}
-	fmt.Printf("\n%d unique keys\n",
-		len(*hashmap))
+	fmt.Printf("\n%s\n", res.Summary())
 }
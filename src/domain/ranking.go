@@ -0,0 +1,136 @@
+package domain
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fields stations can be ranked by
+const (
+	RANK_MIN   = "min"
+	RANK_MEAN  = "mean"
+	RANK_MAX   = "max"
+	RANK_COUNT = "count"
+)
+
+// Ranking selects the K stations with the highest value of Field, or the lowest when Ascending
+type Ranking struct {
+	Field     string
+	K         int
+	Ascending bool
+}
+
+// ParseRanking parses field:k[:asc|desc], descending by default, e.g. max:10 or mean:5:asc
+func ParseRanking(s string) (Ranking, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Ranking{}, fmt.Errorf("invalid ranking %q, expected field:k[:asc|desc]", s)
+	}
+	rank := Ranking{Field: parts[0]}
+	if rankValue(rank.Field) == nil {
+		return Ranking{}, fmt.Errorf("unknown ranking field %q, expected one of: %s, %s, %s, %s",
+			rank.Field, RANK_MIN, RANK_MEAN, RANK_MAX, RANK_COUNT)
+	}
+	k, err := strconv.Atoi(parts[1])
+	if err != nil || k <= 0 {
+		return Ranking{}, fmt.Errorf("invalid number of stations %q in ranking, expected a positive number", parts[1])
+	}
+	rank.K = k
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "asc":
+			rank.Ascending = true
+		case "desc":
+		default:
+			return Ranking{}, fmt.Errorf("invalid order %q in ranking, expected asc or desc", parts[2])
+		}
+	}
+	return rank, nil
+}
+
+func (k Ranking) String() string {
+	order := "desc"
+	if k.Ascending {
+		order = "asc"
+	}
+	return fmt.Sprintf("%s:%d:%s", k.Field, k.K, order)
+}
+
+func rankValue(field string) func(*StationDataInt) int {
+	switch field {
+	case RANK_MIN:
+		return func(s *StationDataInt) int { return s.Min }
+	case RANK_MEAN:
+		return func(s *StationDataInt) int { return s.Mean() }
+	case RANK_MAX:
+		return func(s *StationDataInt) int { return s.Max }
+	case RANK_COUNT:
+		return func(s *StationDataInt) int { return s.Count }
+	}
+	return nil
+}
+
+type ranked struct {
+	name  string
+	value int
+}
+
+// rankHeap holds the best stations seen so far with the worst of them at the root
+type rankHeap struct {
+	items  []ranked
+	better func(a, b ranked) bool
+}
+
+func (h rankHeap) Len() int           { return len(h.items) }
+func (h rankHeap) Less(i, j int) bool { return h.better(h.items[j], h.items[i]) }
+func (h rankHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *rankHeap) Push(x any)        { h.items = append(h.items, x.(ranked)) }
+func (h *rankHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// Top returns the names of the stations selected by rank, best first and ties by name.
+// Only rank.K stations are kept while ranking, O(n log k) for n stations.
+func (r *Result) Top(rank Ranking) []string {
+	value := rankValue(rank.Field)
+	if value == nil || rank.K <= 0 {
+		return nil
+	}
+	h := &rankHeap{better: func(a, b ranked) bool {
+		if a.value != b.value {
+			return (a.value < b.value) == rank.Ascending
+		}
+		return a.name < b.name
+	}}
+	for name, s := range r.Stations {
+		item := ranked{name: name, value: value(s)}
+		if h.Len() < rank.K {
+			heap.Push(h, item)
+		} else if h.better(item, h.items[0]) {
+			h.items[0] = item
+			heap.Fix(h, 0)
+		}
+	}
+	sort.Slice(h.items, func(i, j int) bool { return h.better(h.items[i], h.items[j]) })
+	names := make([]string, len(h.items))
+	for i, item := range h.items {
+		names[i] = item.name
+	}
+	return names
+}
+
+// Ranked returns a copy of r with only the stations selected by rank, in their ranking order
+func (r *Result) Ranked(rank Ranking) *Result {
+	res := *r
+	res.Order = r.Top(rank)
+	res.Stations = make(map[string]*StationDataInt, len(res.Order))
+	for _, name := range res.Order {
+		res.Stations[name] = r.Stations[name]
+	}
+	return &res
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func TestRanking(t *testing.T) {
+
+	t.Run("Parse", func(t *testing.T) {
+		rank, err := ParseRanking("max:10")
+		AssertTrue(t, err == nil)
+		AssertEqual(t, rank, Ranking{Field: RANK_MAX, K: 10})
+		rank, err = ParseRanking("mean:5:asc")
+		AssertTrue(t, err == nil)
+		AssertEqual(t, rank, Ranking{Field: RANK_MEAN, K: 5, Ascending: true})
+		AssertEqual(t, rank.String(), "mean:5:asc")
+		for _, invalid := range []string{"", "max", "max:0", "max:x", "avg:3", "max:3:up", "max:3:asc:x"} {
+			_, err := ParseRanking(invalid)
+			AssertTrue(t, err != nil)
+		}
+	})
+
+	res := NewResult()
+	res.Add("Hot", 400)
+	res.Add("Cold", -300)
+	res.Add("Cold", -100)
+	res.Add("Busy", 100)
+	res.Add("Busy", 100)
+	res.Add("Busy", 100)
+	res.Add("Warm", 400)
+
+	t.Run("Top orders by value then name", func(t *testing.T) {
+		AssertEqual(t, fmt.Sprint(res.Top(Ranking{Field: RANK_MAX, K: 3})), "[Hot Warm Busy]")
+		AssertEqual(t, fmt.Sprint(res.Top(Ranking{Field: RANK_MEAN, K: 1, Ascending: true})), "[Cold]")
+		AssertEqual(t, fmt.Sprint(res.Top(Ranking{Field: RANK_COUNT, K: 10})), "[Busy Cold Hot Warm]")
+	})
+
+	t.Run("Ranked results are written in ranking order", func(t *testing.T) {
+		ranked := res.Ranked(Ranking{Field: RANK_MIN, K: 2, Ascending: true})
+		AssertEqual(t, ranked.String(), "{Cold=-30.0/-20.0/-10.0, Busy=10.0/10.0/10.0}")
+		AssertEqual(t, ranked.Lines, res.Lines)
+		AssertEqual(t, res.NoOfStations(), 4)
+	})
+
+	t.Run("Heap agrees with sorting all stations", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		many := NewResult()
+		for i := 0; i < 5000; i++ {
+			many.Add(fmt.Sprintf("s%d", rng.Intn(1000)), rng.Intn(HISTOGRAM_BUCKETS)+MIN_TEMPERATURE)
+		}
+		names := many.SortedKeys()
+		sort.SliceStable(names, func(i, j int) bool { return many.Stations[names[i]].Max > many.Stations[names[j]].Max })
+		AssertEqual(t, fmt.Sprint(many.Top(Ranking{Field: RANK_MAX, K: 25})), fmt.Sprint(names[:25]))
+	})
+}
@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// OnReject is what happens to a run when a line fails to parse
+type OnReject int
+
+const (
+	REJECT_SKIP       OnReject = iota // count the line and continue
+	REJECT_FAIL                       // stop the run at the first rejected line
+	REJECT_QUARANTINE                 // write every rejected line to a quarantine file
+)
+
+var onRejectNames = map[OnReject]string{
+	REJECT_SKIP:       "skip",
+	REJECT_FAIL:       "fail",
+	REJECT_QUARANTINE: "quarantine",
+}
+
+func (o OnReject) String() string {
+	return onRejectNames[o]
+}
+
+func ParseOnReject(name string) (OnReject, error) {
+	for o, n := range onRejectNames {
+		if n == name {
+			return o, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown reject policy %q, expected skip, fail or quarantine", name)
+}
+
+var ErrRejectBudget = errors.New("too many rejected lines")
+
+// Number of lines a run sees before its reject budget is checked while it is running
+const MIN_REJECT_SAMPLE = 1 << 20
+
+// RejectPolicy is shared by the error logs of a run, safe for concurrent use
+type RejectPolicy struct {
+	OnReject   OnReject
+	Budget     *RejectBudget   // checked at every rejected line, may be nil
+	Quarantine *Quarantine     // receives the rejected lines as they are rejected, may be nil
+	File       string          // name of the input, set on the rejected lines
+	Abort      func(err error) // called once with the error failing the run, may be nil
+
+	mu     sync.Mutex
+	failed error
+}
+
+func (p *RejectPolicy) reject(err *ParseError) {
+	if p.File != "" {
+		err.File = p.File
+	}
+	if p.Quarantine != nil {
+		p.Quarantine.Write(err)
+	}
+	if p.OnReject == REJECT_FAIL {
+		p.fail(err)
+	} else if berr := p.Budget.reject(); berr != nil {
+		p.fail(berr)
+	}
+}
+
+func (p *RejectPolicy) fail(err error) {
+	p.mu.Lock()
+	first := p.failed == nil
+	if first {
+		p.failed = err
+	}
+	p.mu.Unlock()
+	if first && p.Abort != nil {
+		p.Abort(err)
+	}
+}
+
+// Failed returns the error that failed the run, the first rejected line or the exceeded budget, nil if none did
+func (p *RejectPolicy) Failed() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.failed
+}
+
+// RejectBudget fails the runs sharing it once more than MaxRate of the lines they have seen are rejected.
+// It is checked after MIN_REJECT_SAMPLE lines, the final result of a run is checked with CheckRejectRate.
+type RejectBudget struct {
+	MaxRate float64
+	seen    func() int64
+	base    int64
+	count   atomic.Int64
+}
+
+// NewRejectBudget counts the lines seen from now on with seen, a running count of lines
+func NewRejectBudget(maxRate float64, seen func() int64) *RejectBudget {
+	return &RejectBudget{MaxRate: maxRate, seen: seen, base: seen()}
+}
+
+func (b *RejectBudget) reject() error {
+	if b == nil || b.MaxRate <= 0 {
+		return nil
+	}
+	rejected := b.count.Add(1)
+	lines := b.seen() - b.base
+	if lines < MIN_REJECT_SAMPLE {
+		return nil
+	}
+	return checkRate(rejected, lines, b.MaxRate)
+}
+
+// CheckRejectRate fails when more than maxRate of the lines of res were rejected, 0 disables the check
+func CheckRejectRate(res *Result, maxRate float64) error {
+	if maxRate <= 0 || res.Lines == 0 {
+		return nil
+	}
+	return checkRate(res.Errors, res.Lines, maxRate)
+}
+
+func checkRate(rejected, lines int64, maxRate float64) error {
+	rate := float64(rejected) / float64(lines)
+	if rate > maxRate {
+		return fmt.Errorf("%w: %d of %d lines (%.2f%%, budget %.2f%%)",
+			ErrRejectBudget, rejected, lines, rate*100, maxRate*100)
+	}
+	return nil
+}
+
+// Quarantine writes the rejected lines of one or more runs as they are rejected, safe for concurrent use.
+// One row per line: the file name when known, line number, byte offset, reason and the line itself separated by tabs.
+type Quarantine struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	err error
+}
+
+func NewQuarantine(w io.Writer) *Quarantine {
+	return &Quarantine{w: bufio.NewWriter(w)}
+}
+
+// Write a rejected line, the first write error is kept and returned by Flush
+func (q *Quarantine) Write(err *ParseError) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err != nil {
+		return
+	}
+	if err.File != "" {
+		q.w.WriteString(err.File)
+		q.w.WriteByte('\t')
+	}
+	_, q.err = fmt.Fprintf(q.w, "%d\t%d\t%v\t%s\n", err.Line, err.Offset, err.Err, err.Text)
+}
+
+// Flush writes the buffered lines
+func (q *Quarantine) Flush() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.err == nil {
+		q.err = q.w.Flush()
+	}
+	return q.err
+}
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func TestRejectBudget(t *testing.T) {
+	var seen int64
+	var aborted error
+	policy := &RejectPolicy{
+		Budget: NewRejectBudget(0.01, func() int64 { return seen }),
+		Abort:  func(err error) { aborted = err },
+	}
+	log := NewErrorLog(policy)
+
+	for i := 0; i < 100; i++ {
+		log.Add(NewParseError("bad", ErrMissingSeparator))
+	}
+	AssertTrue(t, aborted == nil) // below the minimum sample
+
+	seen = MIN_REJECT_SAMPLE
+	for i := 0; i < MIN_REJECT_SAMPLE/100; i++ {
+		log.Add(NewParseError("bad", ErrMissingSeparator))
+	}
+	AssertTrue(t, errors.Is(aborted, ErrRejectBudget))
+	AssertTrue(t, errors.Is(policy.Failed(), ErrRejectBudget))
+	AssertEqual(t, log.Count(), int64(100+MIN_REJECT_SAMPLE/100))
+	AssertEqual(t, len(log.Errors()), MAX_LOGGED_ERRORS)
+}
@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Timings of a pipeline run
+type Timings struct {
+	Started time.Time
+	Process time.Duration // reading, parsing and aggregating
+	Merge   time.Duration // combining partial results
+	Total   time.Duration
+}
+
+// Result is the structured output of a pipeline run, temperatures in tenths of a degree
+type Result struct {
+	Stations    map[string]*StationDataInt
+	Lines       int64
+	Bytes       int64
+	Errors      int64
+	Filtered    int64         // lines left out by a Filter, included in Lines
+	ParseErrors []*ParseError // first MAX_LOGGED_ERRORS rejected lines
+	Timings     Timings
+	Table       *TableStats // station table statistics, nil when the pipeline uses a map
+	Partial     bool        // the run was interrupted before the end of the input
+	Offset      int64       // with Partial, all input before Offset is aggregated
+	Merged      bool        // merges the results of several inputs, Offset does not apply
+	Order       []string    // station order of a ranking, see Ranked, nil for by name
+}
+
+func NewResult() *Result {
+	return &Result{
+		Stations: make(map[string]*StationDataInt),
+	}
+}
+
+func (r *Result) NoOfStations() int {
+	return len(r.Stations)
+}
+
+// Add a single reading in tenths of a degree
+func (r *Result) Add(name string, value int) {
+	station, exists := r.Stations[name]
+	if !exists {
+		r.Stations[name] = &StationDataInt{
+			Min:   value,
+			Max:   value,
+			Sum:   value,
+			Count: 1,
+		}
+	} else {
+		station.Min = min(station.Min, value)
+		station.Max = max(station.Max, value)
+		station.Sum += value
+		station.Count++
+	}
+}
+
+// Merge combines another partial result into r
+func (r *Result) Merge(o *Result) {
+	for k, v := range o.Stations {
+		station, exists := r.Stations[k]
+		if !exists {
+			data := *v
+			data.Stats = v.Stats.Clone()
+			r.Stations[k] = &data
+		} else {
+			station.Merge(*v)
+		}
+	}
+	r.Lines += o.Lines
+	r.Bytes += o.Bytes
+	r.Errors += o.Errors
+	r.Filtered += o.Filtered
+	r.Partial = r.Partial || o.Partial
+	r.Merged = r.Merged || o.Merged
+	for _, err := range o.ParseErrors {
+		if len(r.ParseErrors) == MAX_LOGGED_ERRORS {
+			break
+		}
+		r.ParseErrors = append(r.ParseErrors, err)
+	}
+	if o.Table != nil {
+		if r.Table == nil {
+			r.Table = &TableStats{}
+		}
+		r.Table.merge(*o.Table)
+	}
+}
+
+func (r *Result) SortedKeys() []string {
+	keys := make([]string, 0, len(r.Stations))
+	for k := range r.Stations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Keys of the stations in output order: the ranking order when there is one, by name otherwise
+func (r *Result) Keys() []string {
+	if r.Order != nil {
+		return r.Order
+	}
+	return r.SortedKeys()
+}
+
+// String returns the challenge output {<station>=<min>/<mean>/<max>, ...}
+func (r *Result) String() string {
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, k := range r.Keys() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(r.Stations[k].String())
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func (r *Result) Summary() string {
+	counts := fmt.Sprintf("%d bytes, %d errors", r.Bytes, r.Errors)
+	if r.Filtered > 0 {
+		counts += fmt.Sprintf(", %d filtered", r.Filtered)
+	}
+	if r.Partial && r.Merged {
+		return fmt.Sprintf("PARTIAL result, interrupted after %s. Processed %d lines (%s), %d unique keys",
+			r.Timings.Total, r.Lines, counts, len(r.Stations))
+	}
+	if r.Partial {
+		return fmt.Sprintf("PARTIAL result, interrupted after %s at byte offset %d. Processed %d lines (%s), %d unique keys",
+			r.Timings.Total, r.Offset, r.Lines, counts, len(r.Stations))
+	}
+	return fmt.Sprintf("Done in %s. Processed %d lines (%s), %d unique keys",
+		r.Timings.Total, r.Lines, counts, len(r.Stations))
+}
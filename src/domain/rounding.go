@@ -0,0 +1,36 @@
+package domain
+
+import "strconv"
+
+// MeanTenths is the mean of count temperatures summing to sum, all in tenths of a degree, rounded like
+// the reference implementation of the challenge: half up toward positive infinity, so 0.25 becomes 0.3
+// and -0.25 becomes -0.2. Integer arithmetic keeps it exact for any sum.
+func MeanTenths(sum int64, count int) int {
+	if count <= 0 {
+		return 0
+	}
+	// floor((sum + count/2) / count) without losing the half of an odd count
+	n, d := 2*sum+int64(count), 2*int64(count)
+	q := n / d
+	if n%d != 0 && n < 0 {
+		q--
+	}
+	return int(q)
+}
+
+// FormatTenths formats tenths of a degree with exactly one decimal, never as -0.0
+func FormatTenths(t int) string {
+	b := make([]byte, 0, 8)
+	if t < 0 {
+		b = append(b, '-')
+		t = -t
+	}
+	b = strconv.AppendInt(b, int64(t/10), 10)
+	b = append(b, '.', byte('0'+t%10))
+	return string(b)
+}
+
+// FormatStation formats min/mean/max in the challenge output format
+func FormatStation(min, mean, max int) string {
+	return FormatTenths(min) + "/" + FormatTenths(mean) + "/" + FormatTenths(max)
+}
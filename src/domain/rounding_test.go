@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"math/big"
+	"testing"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func TestRounding(t *testing.T) {
+
+	t.Run("Means round half up toward positive infinity", func(t *testing.T) {
+		cases := []struct{ sum, count, want int }{
+			{5, 2, 3},     // 0.25 -> 0.3
+			{-5, 2, -2},   // -0.25 -> -0.2
+			{-15, 2, -7},  // -0.75 -> -0.7
+			{-1, 2, 0},    // -0.05 -> 0.0
+			{2, 3, 1},     // 0.0667 -> 0.1
+			{-2, 3, -1},   // -0.0667 -> -0.1
+			{999, 1, 999}, // 99.9
+			{0, 0, 0},
+		}
+		for _, c := range cases {
+			AssertEqual(t, MeanTenths(int64(c.sum), c.count), c.want)
+		}
+	})
+
+	t.Run("Tenths have exactly one decimal", func(t *testing.T) {
+		cases := map[int]string{0: "0.0", 5: "0.5", -5: "-0.5", 100: "10.0", 999: "99.9", -999: "-99.9", -10: "-1.0"}
+		for tenths, want := range cases {
+			AssertEqual(t, FormatTenths(tenths), want)
+		}
+	})
+
+	t.Run("Every result type formats the same", func(t *testing.T) {
+		data := StationDataInt{Min: -15, Max: 10, Sum: -5, Count: 2}
+		AssertEqual(t, data.String(), "-1.5/-0.2/1.0")
+		AssertEqual(t, StationData{Min: -1.5, Max: 1.0, Sum: -0.5, Count: 2}.String(), "-1.5/-0.2/1.0")
+		station := &ByteStation{StationId: []byte("A"), Min: -15, Max: 10, Sum: -5, Count: 2}
+		AssertEqual(t, station.String(), "A=-1.5/-0.2/1.0")
+	})
+}
+
+func FuzzMeanTenths(f *testing.F) {
+	f.Add(int64(5), 2)
+	f.Add(int64(-5), 2)
+	f.Add(int64(-999_000_000_000), 1_000_000_000)
+	f.Fuzz(func(t *testing.T, sum int64, count int) {
+		if count <= 0 || sum > 1<<50 || sum < -(1<<50) {
+			return
+		}
+		// floor(sum/count + 1/2) with exact rationals
+		want := new(big.Rat).SetFrac64(sum, int64(count))
+		want.Add(want, big.NewRat(1, 2))
+		floor := new(big.Int).Div(want.Num(), want.Denom()) // Euclidean division floors for positive denominators
+		if got := MeanTenths(sum, count); int64(got) != floor.Int64() {
+			t.Fatalf("MeanTenths(%d, %d) = %d, want %d", sum, count, got, floor.Int64())
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// State files hold a partial Result losslessly so it can be merged with others:
+//
+//	# <lines> <bytes> <errors> <filtered> <offset>
+//	<station>;<min>;<max>;<sum>;<count>[;<temperature>:<count>,...]
+//
+// with temperatures in tenths of a degree. offset is the byte offset a partial result is
+// aggregated up to and -1 for a complete one. The optional last field is the histogram of
+// the extended statistics, see Stats, with the temperatures that occur. Files written
+// before filtered and offset were added are read as complete.
+
+func WriteState(w io.Writer, r *Result) error {
+	bw := bufio.NewWriter(w)
+	offset := int64(-1)
+	if r.Partial {
+		offset = r.Offset
+	}
+	fmt.Fprintf(bw, "# %d %d %d %d %d\n", r.Lines, r.Bytes, r.Errors, r.Filtered, offset)
+	for _, k := range r.SortedKeys() {
+		s := r.Stations[k]
+		fmt.Fprintf(bw, "%s;%d;%d;%d;%d", k, s.Min, s.Max, s.Sum, s.Count)
+		if s.Stats != nil {
+			bw.WriteByte(';')
+			sep := ""
+			for i, c := range s.Stats.Histogram {
+				if c > 0 {
+					fmt.Fprintf(bw, "%s%d:%d", sep, i+MIN_TEMPERATURE, c)
+					sep = ","
+				}
+			}
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+func ReadState(r io.Reader) (*Result, error) {
+	res := NewResult()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, bufio.MaxScanTokenSize<<4) // histograms of up to HISTOGRAM_BUCKETS temperatures
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if err := readStateHeader(res, line); err != nil {
+				return nil, fmt.Errorf("line %d: invalid state header: %w", lineNo, err)
+			}
+			continue
+		}
+
+		parts := strings.Split(line, ";")
+		if len(parts) != 5 && len(parts) != 6 {
+			return nil, fmt.Errorf("line %d: expected 5 or 6 fields, got %d", lineNo, len(parts))
+		}
+		var values [4]int
+		for i, p := range parts[1:5] {
+			v, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			values[i] = v
+		}
+		data := StationDataInt{Min: values[0], Max: values[1], Sum: values[2], Count: values[3]}
+		if len(parts) == 6 {
+			stats, err := readHistogram(parts[5])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			data.Stats = stats
+		}
+		if station, exists := res.Stations[parts[0]]; exists {
+			station.Merge(data)
+		} else {
+			res.Stations[parts[0]] = &data
+		}
+	}
+	return res, scanner.Err()
+}
+
+// readStateHeader adds the counts of a header line to res
+func readStateHeader(res *Result, line string) error {
+	fields := strings.Fields(strings.TrimPrefix(line, "#"))
+	if len(fields) != 3 && len(fields) != 5 {
+		return fmt.Errorf("expected 3 or 5 numbers, got %d", len(fields))
+	}
+	values := make([]int64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return err
+		}
+		values[i] = v
+	}
+	res.Lines += values[0]
+	res.Bytes += values[1]
+	res.Errors += values[2]
+	if len(values) == 5 {
+		res.Filtered += values[3]
+		if values[4] >= 0 {
+			res.Partial, res.Offset = true, values[4]
+		}
+	}
+	return nil
+}
+
+// readHistogram reads the extended statistics of a station from <temperature>:<count>,...
+func readHistogram(field string) (*Stats, error) {
+	stats := NewStats()
+	if field == "" {
+		return stats, nil
+	}
+	for _, bucket := range strings.Split(field, ",") {
+		t, c, ok := strings.Cut(bucket, ":")
+		temp, err := strconv.Atoi(t)
+		if err != nil || !ok || temp < MIN_TEMPERATURE || temp > MAX_TEMPERATURE {
+			return nil, fmt.Errorf("invalid histogram bucket %q", bucket)
+		}
+		count, err := strconv.ParseInt(c, 10, 64)
+		if err != nil || count <= 0 {
+			return nil, fmt.Errorf("invalid histogram bucket %q", bucket)
+		}
+		stats.AddCount(temp, count)
+	}
+	return stats, nil
+}
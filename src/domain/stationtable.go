@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"bytes"
+	"fmt"
+	"hash/maphash"
+)
+
+type HashFunc int
+
+const (
+	HASH_FNV1A   HashFunc = iota // 32 bit FNV-1a, see HashFNV1a
+	HASH_SIMPLE                  // 31 multiplier, see HashSimple
+	HASH_MAPHASH                 // hash/maphash with a random seed
+)
+
+var hashFuncNames = map[HashFunc]string{
+	HASH_FNV1A:   "fnv1a",
+	HASH_SIMPLE:  "simple",
+	HASH_MAPHASH: "maphash",
+}
+
+func (h HashFunc) String() string {
+	return hashFuncNames[h]
+}
+
+func ParseHashFunc(name string) (HashFunc, error) {
+	for h, n := range hashFuncNames {
+		if n == name {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown hash function %q, expected fnv1a, simple or maphash", name)
+}
+
+func HashFNV1a(b []byte) uint64 {
+	const prime = 16777619
+	hash := uint32(2166136261)
+	for _, c := range b {
+		hash = (hash ^ uint32(c)) * prime
+	}
+	return uint64(hash)
+}
+
+func HashSimple(b []byte) uint64 {
+	hash := uint64(17)
+	for _, c := range b {
+		hash = hash*31 + uint64(c)
+	}
+	return hash
+}
+
+// TableStats describe how well the hash function spreads the station names
+type TableStats struct {
+	Lookups        int64 // calls to GetOrInsert
+	Probes         int64 // occupied slots skipped over in all lookups
+	MaxProbe       int   // most slots skipped over in a single lookup
+	Collisions     int64 // lookups that skipped at least one slot
+	HashCollisions int64 // skipped slots holding a different name with the same full hash
+	Resizes        int
+	NameBytes      int64 // bytes of interned station names
+	ArenaBytes     int64 // bytes allocated for interned station names
+}
+
+func (s TableStats) AvgProbe() float64 {
+	if s.Lookups == 0 {
+		return 0
+	}
+	return float64(s.Probes) / float64(s.Lookups)
+}
+
+func (s *TableStats) merge(o TableStats) {
+	s.Lookups += o.Lookups
+	s.Probes += o.Probes
+	s.MaxProbe = max(s.MaxProbe, o.MaxProbe)
+	s.Collisions += o.Collisions
+	s.HashCollisions += o.HashCollisions
+	s.Resizes += o.Resizes
+	s.NameBytes += o.NameBytes
+	s.ArenaBytes += o.ArenaBytes
+}
+
+func (s TableStats) String() string {
+	return fmt.Sprintf("%d lookups, %d collisions, %d hash collisions, avg probe %.3f, max probe %d, %d resizes, names %d bytes in %d bytes of arena",
+		s.Lookups, s.Collisions, s.HashCollisions, s.AvgProbe(), s.MaxProbe, s.Resizes, s.NameBytes, s.ArenaBytes)
+}
+
+type tableSlot struct {
+	hash    uint64
+	station *ByteStation
+}
+
+// StationTable is an open addressing (linear probing) hash table of stations keyed by name.
+// Names are compared in full, so names with equal hashes are kept apart, and interned
+// on insert so callers may reuse the buffer holding name. Not safe for concurrent use.
+type StationTable struct {
+	slots    []tableSlot
+	mask     uint64
+	count    int
+	hashFunc HashFunc
+	seed     maphash.Seed
+	names    NameArena
+	stats    TableStats
+}
+
+const (
+	TABLE_MIN_CAPACITY = 1024
+	TABLE_MAX_LOAD     = 0.5
+)
+
+func NewStationTable(hashFunc HashFunc, capacity int) *StationTable {
+	size := TABLE_MIN_CAPACITY
+	for float64(size)*TABLE_MAX_LOAD < float64(capacity) {
+		size <<= 1
+	}
+	return &StationTable{
+		slots:    make([]tableSlot, size),
+		mask:     uint64(size - 1),
+		hashFunc: hashFunc,
+		seed:     maphash.MakeSeed(),
+	}
+}
+
+func (t *StationTable) Hash(name []byte) uint64 {
+	switch t.hashFunc {
+	case HASH_SIMPLE:
+		return HashSimple(name)
+	case HASH_MAPHASH:
+		return maphash.Bytes(t.seed, name)
+	default:
+		return HashFNV1a(name)
+	}
+}
+
+func (t *StationTable) Len() int {
+	return t.count
+}
+
+func (t *StationTable) Stats() TableStats {
+	stats := t.stats
+	stats.NameBytes = t.names.Used()
+	stats.ArenaBytes = t.names.Allocated()
+	return stats
+}
+
+// GetOrInsert returns the station named name, inserting an empty one if it does not exist
+func (t *StationTable) GetOrInsert(name []byte) (*ByteStation, bool) {
+	if float64(t.count+1) > float64(len(t.slots))*TABLE_MAX_LOAD {
+		t.grow()
+	}
+	hash := t.Hash(name)
+	t.stats.Lookups++
+
+	probe := 0
+	for i := hash & t.mask; ; i = (i + 1) & t.mask {
+		slot := &t.slots[i]
+		if slot.station == nil {
+			t.record(probe)
+			slot.hash = hash
+			slot.station = &ByteStation{StationId: t.names.Intern(name)}
+			t.count++
+			return slot.station, false
+		}
+		if slot.hash == hash && bytes.Equal(slot.station.StationId, name) {
+			t.record(probe)
+			return slot.station, true
+		}
+		if slot.hash == hash {
+			t.stats.HashCollisions++
+		}
+		probe++
+	}
+}
+
+func (t *StationTable) record(probe int) {
+	if probe > 0 {
+		t.stats.Collisions++
+		t.stats.Probes += int64(probe)
+		t.stats.MaxProbe = max(t.stats.MaxProbe, probe)
+	}
+}
+
+func (t *StationTable) grow() {
+	old := t.slots
+	t.slots = make([]tableSlot, len(old)*2)
+	t.mask = uint64(len(t.slots) - 1)
+	t.stats.Resizes++
+	for _, slot := range old {
+		if slot.station == nil {
+			continue
+		}
+		i := slot.hash & t.mask
+		for t.slots[i].station != nil {
+			i = (i + 1) & t.mask
+		}
+		t.slots[i] = slot
+	}
+}
+
+// Stations in table order
+func (t *StationTable) Stations() []*ByteStation {
+	stations := make([]*ByteStation, 0, t.count)
+	for _, slot := range t.slots {
+		if slot.station != nil {
+			stations = append(stations, slot.station)
+		}
+	}
+	return stations
+}
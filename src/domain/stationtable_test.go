@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func TestStationTable(t *testing.T) {
+
+	t.Run("Names with equal hashes are kept apart", func(t *testing.T) {
+		AssertEqual(t, HashSimple([]byte("Aa")), HashSimple([]byte("BB")))
+
+		result := NewByteResultWithHash(HASH_SIMPLE)
+		result.Add(ByteStationReading{StationId: []byte("Aa"), Temperature: 10})
+		result.Add(ByteStationReading{StationId: []byte("BB"), Temperature: -10})
+		result.Add(ByteStationReading{StationId: []byte("Aa"), Temperature: 30})
+
+		AssertEqual(t, result.NoOfStations(), 2)
+		res := result.ToResult()
+		AssertEqual(t, res.Stations["Aa"].Count, 2)
+		AssertEqual(t, res.Stations["BB"].Count, 1)
+		AssertEqual(t, res.Table.HashCollisions, int64(1))
+	})
+
+	t.Run("Grows past capacity with every hash function", func(t *testing.T) {
+		for _, h := range []HashFunc{HASH_FNV1A, HASH_SIMPLE, HASH_MAPHASH} {
+			table := NewStationTable(h, 0)
+			for i := 0; i < 10000; i++ {
+				station, exists := table.GetOrInsert([]byte(fmt.Sprintf("station-%d", i)))
+				AssertFalse(t, exists)
+				station.Count++
+			}
+			for i := 0; i < 10000; i++ {
+				station, exists := table.GetOrInsert([]byte(fmt.Sprintf("station-%d", i)))
+				AssertTrue(t, exists)
+				AssertEqual(t, station.Count, 1)
+			}
+			AssertEqual(t, table.Len(), 10000)
+			AssertEqual(t, table.Stats().Lookups, int64(20000))
+			AssertTrue(t, table.Stats().Resizes > 0)
+		}
+	})
+
+	t.Run("Merge partial results", func(t *testing.T) {
+		a := NewByteResult()
+		a.Add(ByteStationReading{StationId: []byte("Oslo"), Temperature: -50})
+		a.Add(ByteStationReading{StationId: []byte("Rome"), Temperature: 200})
+		b := NewByteResultWithHash(HASH_MAPHASH)
+		b.Add(ByteStationReading{StationId: []byte("Oslo"), Temperature: 10})
+		b.Add(ByteStationReading{StationId: []byte("Lima"), Temperature: 150})
+
+		a.Merge(b)
+		AssertEqual(t, a.NoOfStations(), 3)
+		AssertEqual(t, a.NoOfInputs(), 4)
+		res := a.ToResult()
+		AssertEqual(t, *res.Stations["Oslo"], StationDataInt{Min: -50, Max: 10, Sum: -40, Count: 2})
+		AssertEqual(t, res.Stations["Lima"].Count, 1)
+	})
+
+	t.Run("Parse hash function", func(t *testing.T) {
+		h, err := ParseHashFunc("maphash")
+		AssertTrue(t, err == nil)
+		AssertEqual(t, h, HASH_MAPHASH)
+		_, err = ParseHashFunc("crc")
+		AssertTrue(t, err != nil)
+	})
+}
+
+func TestNameArena(t *testing.T) {
+	var arena NameArena
+	buf := []byte("Hamburg;12.0")
+	name := arena.Intern(buf[:7])
+	copy(buf, "XXXXXXX")
+
+	AssertEqual(t, string(name), "Hamburg")
+	AssertEqual(t, cap(name), 7)
+	AssertEqual(t, arena.Used(), int64(7))
+	AssertEqual(t, arena.Allocated(), int64(NAME_ARENA_BLOCK_SIZE))
+
+	long := make([]byte, NAME_ARENA_BLOCK_SIZE+1)
+	AssertEqual(t, len(arena.Intern(long)), NAME_ARENA_BLOCK_SIZE+1)
+	AssertEqual(t, arena.Allocated(), int64(2*NAME_ARENA_BLOCK_SIZE+1))
+}
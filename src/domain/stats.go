@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+)
+
+// HISTOGRAM_BUCKETS is one bucket per tenth of a degree from MIN_TEMPERATURE to MAX_TEMPERATURE
+const HISTOGRAM_BUCKETS = MAX_TEMPERATURE - MIN_TEMPERATURE + 1
+
+// Stats are the extended statistics of a station, temperatures in tenths of a degree.
+// The variance is tracked with Welford's algorithm, percentiles and mode are exact
+// from a histogram of all temperatures. A Stats takes 16 KB.
+type Stats struct {
+	Count     int64
+	Mean      float64                  // running mean
+	M2        float64                  // sum of squared differences from the mean
+	Histogram [HISTOGRAM_BUCKETS]int64 // temperatures per tenth, index 0 is MIN_TEMPERATURE
+}
+
+func NewStats() *Stats {
+	return &Stats{}
+}
+
+// Add a temperature in MIN_TEMPERATURE..MAX_TEMPERATURE
+func (s *Stats) Add(t int) {
+	s.Count++
+	d := float64(t) - s.Mean
+	s.Mean += d / float64(s.Count)
+	s.M2 += d * (float64(t) - s.Mean)
+	s.Histogram[t-MIN_TEMPERATURE]++
+}
+
+// AddFloat adds a temperature in degrees, rounded to tenths
+func (s *Stats) AddFloat(t float64) {
+	s.Add(int(math.Round(t * 10)))
+}
+
+// AddCount adds a temperature n times, like Merge with statistics of n equal temperatures
+func (s *Stats) AddCount(t int, n int64) {
+	total := s.Count + n
+	d := float64(t) - s.Mean
+	s.Mean += d * float64(n) / float64(total)
+	s.M2 += d * d * float64(s.Count) * float64(n) / float64(total)
+	s.Count = total
+	s.Histogram[t-MIN_TEMPERATURE] += n
+}
+
+// Merge combines the statistics of another partial result into s
+func (s *Stats) Merge(o *Stats) {
+	if o == nil || o.Count == 0 {
+		return
+	}
+	n := s.Count + o.Count
+	d := o.Mean - s.Mean
+	s.Mean += d * float64(o.Count) / float64(n)
+	s.M2 += o.M2 + d*d*float64(s.Count)*float64(o.Count)/float64(n)
+	s.Count = n
+	for i, c := range o.Histogram {
+		s.Histogram[i] += c
+	}
+}
+
+// Clone returns a copy of s, nil when s is nil
+func (s *Stats) Clone() *Stats {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
+// mergeStats merges o into *s, copying o when *s is nil
+func mergeStats(s **Stats, o *Stats) {
+	switch {
+	case o == nil:
+	case *s == nil:
+		*s = o.Clone()
+	default:
+		(*s).Merge(o)
+	}
+}
+
+// Variance of the population in tenths of a degree squared
+func (s *Stats) Variance() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.M2 / float64(s.Count)
+}
+
+// StdDev is the standard deviation of the population in tenths of a degree
+func (s *Stats) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Percentile p (0 < p <= 100) with the nearest rank method: the lowest temperature
+// that at least p percent of the temperatures are lower than or equal to
+func (s *Stats) Percentile(p float64) int {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := max(1, int64(math.Ceil(p/100*float64(s.Count))))
+	var seen int64
+	for i, c := range s.Histogram {
+		seen += c
+		if seen >= rank {
+			return i + MIN_TEMPERATURE
+		}
+	}
+	return MAX_TEMPERATURE
+}
+
+// Median is the 50th percentile, the lower middle temperature for an even count
+func (s *Stats) Median() int {
+	return s.Percentile(50)
+}
+
+// Mode is the most frequent temperature, the lowest of them on a tie
+func (s *Stats) Mode() int {
+	mode := 0
+	for i, c := range s.Histogram {
+		if c > s.Histogram[mode] {
+			mode = i
+		}
+	}
+	return mode + MIN_TEMPERATURE
+}
+
+func (s *Stats) String() string {
+	return fmt.Sprintf("stddev=%.2f variance=%.2f median=%s p90=%s p95=%s p99=%s mode=%s",
+		s.StdDev()/10, s.Variance()/100, FormatTenths(s.Median()), FormatTenths(s.Percentile(90)),
+		FormatTenths(s.Percentile(95)), FormatTenths(s.Percentile(99)), FormatTenths(s.Mode()))
+}
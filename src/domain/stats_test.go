@@ -0,0 +1,112 @@
+package domain
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func TestStats(t *testing.T) {
+
+	t.Run("Percentiles use the nearest rank", func(t *testing.T) {
+		s := NewStats()
+		for _, v := range []int{10, 20, 20, 99} {
+			s.Add(v)
+		}
+		AssertEqual(t, s.Median(), 20)
+		AssertEqual(t, s.Percentile(25), 10)
+		AssertEqual(t, s.Percentile(75), 20)
+		AssertEqual(t, s.Percentile(90), 99)
+		AssertEqual(t, s.Percentile(100), 99)
+		AssertEqual(t, s.Mode(), 20)
+		AssertEqual(t, NewStats().Median(), 0)
+	})
+
+	t.Run("Boundaries and ties", func(t *testing.T) {
+		s := NewStats()
+		for _, v := range []int{MAX_TEMPERATURE, MIN_TEMPERATURE, 0, MAX_TEMPERATURE, MIN_TEMPERATURE} {
+			s.Add(v)
+		}
+		AssertEqual(t, s.Mode(), MIN_TEMPERATURE)
+		AssertEqual(t, s.Percentile(99), MAX_TEMPERATURE)
+		AssertEqual(t, s.Median(), 0)
+	})
+
+	t.Run("Merged statistics equal those of all values", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(1))
+		values := make([]int, 10000)
+		all, parts := NewStats(), []*Stats{NewStats(), NewStats(), NewStats()}
+		for i := range values {
+			values[i] = rng.Intn(HISTOGRAM_BUCKETS) + MIN_TEMPERATURE
+			all.Add(values[i])
+			parts[rng.Intn(len(parts))].Add(values[i])
+		}
+		merged := NewStats()
+		for _, p := range parts {
+			merged.Merge(p)
+		}
+
+		var sum, squares float64
+		for _, v := range values {
+			sum += float64(v)
+		}
+		mean := sum / float64(len(values))
+		for _, v := range values {
+			squares += (float64(v) - mean) * (float64(v) - mean)
+		}
+		variance := squares / float64(len(values))
+		sort.Ints(values)
+
+		for _, s := range []*Stats{all, merged} {
+			AssertTrue(t, math.Abs(s.Variance()-variance) < 1e-6*variance)
+			AssertTrue(t, math.Abs(s.Mean-mean) < 1e-9*math.Abs(mean)+1e-9)
+			AssertEqual(t, s.Median(), values[len(values)/2-1])
+			AssertEqual(t, s.Percentile(99), values[int(math.Ceil(0.99*float64(len(values))))-1])
+			AssertEqual(t, s.Histogram, all.Histogram)
+		}
+	})
+
+	t.Run("Merging results copies the statistics", func(t *testing.T) {
+		a, b := NewResult(), NewResult()
+		b.Add("x", 10)
+		b.Stations["x"].Stats = NewStats()
+		b.Stations["x"].Stats.Add(10)
+		a.Merge(b)
+		a.Merge(b)
+		AssertEqual(t, a.Stations["x"].Stats.Count, int64(2))
+		AssertEqual(t, b.Stations["x"].Stats.Count, int64(1))
+	})
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	res := NewResult()
+	res.Add("a", 10)
+	res.Add("a", -35)
+	res.Add("b", 990)
+	res.Stations["a"].Stats = NewStats()
+	res.Stations["a"].Stats.Add(10)
+	res.Stations["a"].Stats.Add(-35)
+	res.Lines, res.Bytes, res.Errors, res.Filtered = 5, 40, 1, 1
+	res.Partial, res.Offset = true, 40
+
+	var buf strings.Builder
+	AssertTrue(t, WriteState(&buf, res) == nil)
+	AssertTrue(t, strings.HasPrefix(buf.String(), "# 5 40 1 1 40\na;-35;10;-25;2;-35:1,10:1\nb;"))
+	read, err := ReadState(strings.NewReader(buf.String()))
+	AssertTrue(t, err == nil)
+	AssertEqual(t, read.String(), res.String())
+	AssertEqual(t, read.Filtered, int64(1))
+	AssertTrue(t, read.Partial && read.Offset == 40)
+	AssertTrue(t, read.Stations["b"].Stats == nil)
+	stats := read.Stations["a"].Stats
+	AssertEqual(t, stats.Count, int64(2))
+	AssertTrue(t, math.Abs(stats.Variance()-res.Stations["a"].Stats.Variance()) < 1e-9)
+
+	old, err := ReadState(strings.NewReader("# 5 40 1\na;-35;10;-25;2\n"))
+	AssertTrue(t, err == nil)
+	AssertTrue(t, !old.Partial && old.Lines == 5)
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Temperatures are fixed point integers in tenths of a degree
+const (
+	MIN_TEMPERATURE = -999
+	MAX_TEMPERATURE = 999
+)
+
+var (
+	ErrMissingSeparator   = errors.New("missing ';' separator")
+	ErrEmptyName          = errors.New("empty station name")
+	ErrInvalidTemperature = errors.New("invalid temperature, expected [-]d[d].d")
+	ErrTemperatureRange   = errors.New("temperature out of range -99.9..99.9")
+)
+
+// ParseError is a rejected line with its position in the input
+type ParseError struct {
+	File   string // name of the input when there are several, empty otherwise
+	Line   int64  // 1-based line number, 0 when unknown
+	Offset int64  // byte offset of the start of the line, -1 when unknown
+	Text   string // the rejected line
+	Err    error  // one of the Err... reasons above
+}
+
+func NewParseError[T ~string | ~[]byte](line T, err error) *ParseError {
+	return &ParseError{Offset: -1, Text: string(line), Err: err}
+}
+
+// AsParseError returns err as a ParseError, wrapping it with line when it is not one
+func AsParseError(err error, line string) *ParseError {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return perr
+	}
+	return NewParseError(line, err)
+}
+
+func (e *ParseError) Error() string {
+	if e.File != "" {
+		return e.File + ": " + (&ParseError{Line: e.Line, Offset: e.Offset, Text: e.Text, Err: e.Err}).Error()
+	}
+	switch {
+	case e.Line > 0 && e.Offset >= 0:
+		return fmt.Sprintf("line %d (offset %d): %v: %q", e.Line, e.Offset, e.Err, e.Text)
+	case e.Line > 0:
+		return fmt.Sprintf("line %d: %v: %q", e.Line, e.Err, e.Text)
+	case e.Offset >= 0:
+		return fmt.Sprintf("offset %d: %v: %q", e.Offset, e.Err, e.Text)
+	}
+	return fmt.Sprintf("%v: %q", e.Err, e.Text)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseLine splits <station name>;<temperature> and parses the temperature, see ParseTemperature.
+// An optional timestamp column between them, <station name>;<timestamp>;<temperature>, is checked
+// and skipped, see ParseTimestamp and ParseTimedLine.
+func ParseLine[T ~string | ~[]byte](line T) (T, int, error) {
+	name, timestamp, temperature, err := splitLine(line)
+	if err != nil {
+		return name, 0, err
+	}
+	if timestamp != nil {
+		if _, err := ParseTimestamp(*timestamp); err != nil {
+			return name, 0, err
+		}
+	}
+	temp, err := ParseTemperature(temperature)
+	return name, temp, err
+}
+
+// splitLine splits a line at its first and last ';', timestamp is nil when the line has no timestamp column
+func splitLine[T ~string | ~[]byte](line T) (name T, timestamp *T, temperature T, err error) {
+	ix := -1
+	for i := 0; i < len(line); i++ {
+		if line[i] == ASCII_SEMICOLON {
+			ix = i
+			break
+		}
+	}
+	if ix == -1 {
+		return line[:0], nil, line[:0], ErrMissingSeparator
+	}
+	if ix == 0 {
+		return line[:0], nil, line[:0], ErrEmptyName
+	}
+	name, temperature = line[:ix], line[ix+1:]
+	// the temperature is short, the timestamp column ends at the last ';'
+	for i := len(temperature) - 1; i >= 0; i-- {
+		if temperature[i] == ASCII_SEMICOLON {
+			column := temperature[:i]
+			return name, &column, temperature[i+1:], nil
+		}
+	}
+	return name, nil, temperature, nil
+}
+
+// ParseTemperature parses [-]d[d].d into tenths of a degree, anything else is rejected
+func ParseTemperature[T ~string | ~[]byte](b T) (int, error) {
+	lg := len(b)
+	i := 0
+	neg := lg > 0 && b[0] == ASCII_MINUS
+	if neg {
+		i++
+	}
+
+	// integer part, at most 3 digits are accumulated so garbage can not overflow
+	n := 0
+	digits := 0
+	for ; i < lg && b[i] >= ASCII_ZERO && b[i] <= ASCII_ZERO+9; i++ {
+		if digits < 3 {
+			n = n*10 + int(b[i]-ASCII_ZERO)
+		}
+		digits++
+	}
+
+	// exactly one decimal
+	if digits == 0 || i+2 != lg || b[i] != ASCII_DOT || b[i+1] < ASCII_ZERO || b[i+1] > ASCII_ZERO+9 {
+		return 0, ErrInvalidTemperature
+	}
+	if digits > 2 {
+		return 0, ErrTemperatureRange
+	}
+
+	n = n*10 + int(b[i+1]-ASCII_ZERO)
+	if neg {
+		n = -n
+	}
+	return n, nil
+}
@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"testing"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func TestParseTemperature(t *testing.T) {
+
+	t.Run("Valid temperatures are parsed to tenths", func(t *testing.T) {
+		valid := map[string]int{
+			"0.0": 0, "-0.0": 0, "1.5": 15, "-1.5": -15, "12.3": 123,
+			"-12.3": -123, "99.9": 999, "-99.9": -999, "05.0": 50,
+		}
+		for s, want := range valid {
+			got, err := ParseTemperature(s)
+			AssertTrue(t, err == nil)
+			AssertEqual(t, got, want)
+		}
+	})
+
+	t.Run("Invalid temperatures are rejected", func(t *testing.T) {
+		invalid := []string{"", "-", ".", "1", "-1", "1.", ".5", "1.23", "1,5", "+1.5", "--1.5", "1.5 ", " 1.5", "abc", "1.a", "1e1"}
+		for _, s := range invalid {
+			_, err := ParseTemperature(s)
+			AssertTrue(t, errors.Is(err, ErrInvalidTemperature))
+		}
+	})
+
+	t.Run("Out of range temperatures are rejected", func(t *testing.T) {
+		for _, s := range []string{"100.0", "-100.0", "123456789012345678901234.5"} {
+			_, err := ParseTemperature(s)
+			AssertTrue(t, errors.Is(err, ErrTemperatureRange))
+		}
+	})
+}
+
+func TestParseLine(t *testing.T) {
+
+	t.Run("Name and temperature", func(t *testing.T) {
+		name, temp, err := ParseLine([]byte("St. John's;-3.4"))
+		AssertTrue(t, err == nil)
+		AssertEqual(t, string(name), "St. John's")
+		AssertEqual(t, temp, -34)
+	})
+
+	t.Run("Names are any non-empty text", func(t *testing.T) {
+		name, temp, err := ParseLine("Zürich 1;0.1")
+		AssertTrue(t, err == nil)
+		AssertEqual(t, name, "Zürich 1")
+		AssertEqual(t, temp, 1)
+	})
+
+	t.Run("Skip the timestamp column", func(t *testing.T) {
+		name, temp, err := ParseLine([]byte("Oslo;2024-05-01T13:45:00Z;-3.4"))
+		AssertTrue(t, err == nil)
+		AssertEqual(t, string(name), "Oslo")
+		AssertEqual(t, temp, -34)
+	})
+
+	t.Run("Reasons of rejected lines", func(t *testing.T) {
+		cases := map[string]error{
+			"Oslo":       ErrMissingSeparator,
+			";1.0":       ErrEmptyName,
+			"Oslo;":      ErrInvalidTemperature,
+			"Oslo;1;2":   ErrInvalidTemperature,
+			"Oslo;-1.0 ": ErrInvalidTemperature,
+			"Oslo;999.9": ErrTemperatureRange,
+			"Oslo;x;1.0": ErrInvalidTimestamp,
+		}
+		for line, want := range cases {
+			_, _, err := ParseLine(line)
+			AssertTrue(t, errors.Is(err, want))
+		}
+	})
+
+	t.Run("Parse errors carry their position", func(t *testing.T) {
+		_, err := ParseStringInt("Oslo;x")
+		var perr *ParseError
+		AssertTrue(t, errors.As(err, &perr))
+		AssertEqual(t, perr.Text, "Oslo;x")
+		AssertTrue(t, errors.Is(err, ErrInvalidTemperature))
+
+		perr.Line, perr.Offset = 3, 42
+		AssertEqual(t, perr.Error(), `line 3 (offset 42): invalid temperature, expected [-]d[d].d: "Oslo;x"`)
+	})
+}
+
+func TestErrorLog(t *testing.T) {
+
+	t.Run("All errors are counted, the first are kept in order", func(t *testing.T) {
+		var log ErrorLog
+		for i := MAX_LOGGED_ERRORS * 2; i > 0; i-- {
+			log.Add(&ParseError{Line: int64(i), Offset: -1, Err: ErrEmptyName})
+		}
+		errs := log.Errors()
+		AssertEqual(t, log.Count(), int64(MAX_LOGGED_ERRORS*2))
+		AssertEqual(t, len(errs), MAX_LOGGED_ERRORS)
+		AssertTrue(t, errs[0].Line < errs[1].Line)
+	})
+
+	t.Run("Merge and shift", func(t *testing.T) {
+		var a, b ErrorLog
+		a.Add(&ParseError{Line: 1, Offset: 0})
+		b.Add(&ParseError{Line: 2, Offset: 10})
+		b.ShiftLines(5)
+		a.Merge(&b)
+		errs := a.Errors()
+		AssertEqual(t, a.Count(), int64(2))
+		AssertEqual(t, errs[1].Line, int64(7))
+	})
+}
+
+var temperatureFormat = regexp.MustCompile(`^-?[0-9]{1,2}\.[0-9]$`)
+
+func FuzzParseTemperature(f *testing.F) {
+	for _, s := range []string{"0.0", "-99.9", "99.9", "100.0", "1.23", "-", "", "1e1", "-.5"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		got, err := ParseTemperature(s)
+		if !temperatureFormat.MatchString(s) {
+			if err == nil {
+				t.Fatalf("%q accepted as %d", s, got)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("%q rejected: %v", s, err)
+		}
+		want, _ := strconv.ParseFloat(s, 64)
+		if got != int(math.Round(want*10)) {
+			t.Fatalf("%q parsed as %d", s, got)
+		}
+		if got < MIN_TEMPERATURE || got > MAX_TEMPERATURE {
+			t.Fatalf("%q out of range: %d", s, got)
+		}
+	})
+}
+
+func FuzzParseLine(f *testing.F) {
+	for _, s := range []string{"Oslo;1.0", ";1.0", "Oslo", "a;b;1.0", "a;1714563900;1.0", "Oslo;-0.0\r"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		name, temp, err := ParseLine(s)
+		bname, btemp, berr := ParseLine([]byte(s))
+		if name != string(bname) || temp != btemp || err != berr {
+			t.Fatalf("%q: string and []byte parsing disagree", s)
+		}
+		if err == nil && (len(name) == 0 || temp < MIN_TEMPERATURE || temp > MAX_TEMPERATURE) {
+			t.Fatalf("%q accepted as %q %d", s, name, temp)
+		}
+	})
+}
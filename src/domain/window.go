@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tumbling windows readings can be aggregated in, in UTC
+const (
+	WINDOW_HOUR  = "hour"
+	WINDOW_DAY   = "day"
+	WINDOW_MONTH = "month"
+)
+
+var (
+	ErrInvalidTimestamp = errors.New("invalid timestamp, expected RFC3339 or epoch seconds or milliseconds")
+	ErrMissingTimestamp = errors.New("missing timestamp")
+)
+
+// ParseWindow checks the name of a window
+func ParseWindow(s string) (string, error) {
+	switch s {
+	case WINDOW_HOUR, WINDOW_DAY, WINDOW_MONTH:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown window %q, expected %s, %s or %s", s, WINDOW_HOUR, WINDOW_DAY, WINDOW_MONTH)
+}
+
+// WindowStart is the start of the window of t, in UTC
+func WindowStart(t time.Time, window string) time.Time {
+	t = t.UTC()
+	switch window {
+	case WINDOW_HOUR:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.UTC)
+	case WINDOW_DAY:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// Epoch timestamps of at least MIN_EPOCH_MILLIS in absolute value are milliseconds, seconds from
+// year 5138 on would be, and milliseconds are valid up to MAX_EPOCH_MILLIS, about the same year
+const (
+	MIN_EPOCH_MILLIS = 100_000_000_000
+	MAX_EPOCH_MILLIS = 100_000_000_000_000
+)
+
+// ParseTimestamp parses an RFC3339 time, e.g. 2024-05-01T13:45:00Z, or integer seconds or milliseconds
+// since the Unix epoch, told apart by their magnitude, see MIN_EPOCH_MILLIS
+func ParseTimestamp[T ~string | ~[]byte](b T) (time.Time, error) {
+	if len(b) == 0 {
+		return time.Time{}, ErrMissingTimestamp
+	}
+	digits := 0
+	for i := 0; i < len(b); i++ {
+		if b[i] >= ASCII_ZERO && b[i] <= ASCII_ZERO+9 || i == 0 && b[i] == ASCII_MINUS {
+			digits++
+		}
+	}
+	if digits == len(b) {
+		epoch, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil || epoch >= MAX_EPOCH_MILLIS || epoch <= -MAX_EPOCH_MILLIS {
+			return time.Time{}, ErrInvalidTimestamp
+		}
+		if epoch >= MIN_EPOCH_MILLIS || epoch <= -MIN_EPOCH_MILLIS {
+			return time.UnixMilli(epoch).UTC(), nil
+		}
+		return time.Unix(epoch, 0).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, string(b))
+	if err != nil {
+		return time.Time{}, ErrInvalidTimestamp
+	}
+	return t, nil
+}
+
+// WindowedResult holds one result per window of the readings. Total has the stations over all
+// windows, the readings without a timestamp and the counts of the run.
+type WindowedResult struct {
+	Window  string
+	Windows map[time.Time]*Result // by start of the window
+	Total   *Result
+	Untimed int64 // readings without a timestamp, in Total only
+}
+
+func NewWindowedResult(window string) *WindowedResult {
+	return &WindowedResult{
+		Window:  window,
+		Windows: make(map[time.Time]*Result),
+		Total:   NewResult(),
+	}
+}
+
+// Result of the window starting at start, created when missing
+func (w *WindowedResult) Result(start time.Time) *Result {
+	res, exists := w.Windows[start]
+	if !exists {
+		res = NewResult()
+		w.Windows[start] = res
+	}
+	return res
+}
+
+// Starts of the windows in time order
+func (w *WindowedResult) Starts() []time.Time {
+	starts := make([]time.Time, 0, len(w.Windows))
+	for start := range w.Windows {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	return starts
+}
+
+// Ranked returns a copy of w with only the stations selected by rank in every window
+func (w *WindowedResult) Ranked(rank Ranking) *WindowedResult {
+	res := *w
+	res.Windows = make(map[time.Time]*Result, len(w.Windows))
+	for start, r := range w.Windows {
+		res.Windows[start] = r.Ranked(rank)
+	}
+	return &res
+}
+
+// String returns one line per window, its start and the challenge output of its stations
+func (w *WindowedResult) String() string {
+	var sb strings.Builder
+	for _, start := range w.Starts() {
+		sb.WriteString(start.Format(time.RFC3339))
+		sb.WriteByte(' ')
+		sb.WriteString(w.Windows[start].String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
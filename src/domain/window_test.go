@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func TestWindow(t *testing.T) {
+
+	t.Run("Parse timestamps", func(t *testing.T) {
+		ts, err := ParseTimestamp("2024-05-01T13:45:00+02:00")
+		AssertTrue(t, err == nil)
+		AssertEqual(t, ts.UTC(), time.Date(2024, 5, 1, 11, 45, 0, 0, time.UTC))
+		ts, err = ParseTimestamp([]byte("1714563900"))
+		AssertTrue(t, err == nil)
+		AssertEqual(t, ts, time.Date(2024, 5, 1, 11, 45, 0, 0, time.UTC))
+		ts, err = ParseTimestamp("1714563900123")
+		AssertTrue(t, err == nil)
+		AssertEqual(t, ts, time.Date(2024, 5, 1, 11, 45, 0, 123e6, time.UTC))
+		for _, invalid := range []string{"2024-05-01", "17145x", "-", "2024-05-01 13:45:00", "1714563900123456"} {
+			_, err := ParseTimestamp(invalid)
+			AssertTrue(t, errors.Is(err, ErrInvalidTimestamp))
+		}
+	})
+
+	t.Run("Parse lines", func(t *testing.T) {
+		data, err := ParseStringIntTime("Oslo;1714563900;-3.4")
+		AssertTrue(t, err == nil)
+		AssertEqual(t, data, StringIntTime{Key: "Oslo", Time: time.Unix(1714563900, 0).UTC(), Value: -34})
+		data, err = ParseStringIntTime("Oslo;-3.4")
+		AssertTrue(t, err == nil)
+		AssertTrue(t, data.Time.IsZero())
+		for line, want := range map[string]error{
+			"Oslo;;1.0":                ErrMissingTimestamp,
+			";1714563900;1.0":          ErrEmptyName,
+			"Oslo;1714563900;1.00":     ErrInvalidTemperature,
+			"Oslo;yesterday;1.0":       ErrInvalidTimestamp,
+			"Oslo;1714563900;1.0;more": ErrInvalidTimestamp,
+		} {
+			_, err := ParseStringIntTime(line)
+			AssertTrue(t, errors.Is(err, want))
+		}
+	})
+
+	t.Run("Window start", func(t *testing.T) {
+		ts := time.Date(2024, 5, 31, 23, 59, 59, 0, time.FixedZone("", -3600))
+		AssertEqual(t, WindowStart(ts, WINDOW_HOUR), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+		AssertEqual(t, WindowStart(ts, WINDOW_DAY), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+		AssertEqual(t, WindowStart(ts.Add(-2*time.Hour), WINDOW_MONTH), time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC))
+		_, err := ParseWindow("week")
+		AssertTrue(t, err != nil)
+	})
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/output"
+	"github.com/brcgo/src/pipelines"
+)
+
+// newFlagSet creates the flag set of a command with a usage header
+func newFlagSet(name, args, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: brcgo %s %s\n\n%s\n\nFlags:\n", name, args, description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// isSet reports whether the flag name was given on the command line
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// parseFlags parses args and returns the exit code to use if the command should stop
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return EXIT_OK, false
+		}
+		return EXIT_USAGE, false
+	}
+	return EXIT_OK, true
+}
+
+// usageError prints msg and the usage of the command
+func usageError(fs *flag.FlagSet, format string, a ...any) int {
+	fmt.Fprintf(fs.Output(), format+"\n\n", a...)
+	fs.Usage()
+	return EXIT_USAGE
+}
+
+// pipelineFlags are the tuning flags shared by the commands running pipelines
+type pipelineFlags struct {
+	workers           *int
+	parserWorkers     *int
+	aggregatorWorkers *int
+	bufferSize        *int
+	hash              *string
+}
+
+func addPipelineFlags(fs *flag.FlagSet) *pipelineFlags {
+	return &pipelineFlags{
+		workers:           fs.Int("p", 1, "Maximum number of concurrent threads (bytes, mmap, workerpool, gzip members)"),
+		parserWorkers:     fs.Int("pw", pipelines.NO_OF_PARSER_WORKERS, "Number of parser workers (rpa, jngo)"),
+		aggregatorWorkers: fs.Int("aw", pipelines.NO_OF_AGGREGATOR_WORKERS, "Number of aggregator workers (rpa, jngo)"),
+		bufferSize:        fs.Int("b", pipelines.BUFFER_SIZE, "Read buffer size in bytes (bytes, mmap fallback)"),
+		hash:              fs.String("hash", domain.HASH_FNV1A.String(), "Station table hash function: fnv1a, simple, maphash (bytes, mmap)"),
+	}
+}
+
+func (f *pipelineFlags) options() (pipelines.Options, error) {
+	if *f.workers <= 0 || *f.parserWorkers <= 0 || *f.aggregatorWorkers <= 0 {
+		return pipelines.Options{}, errors.New("number of workers must be greater than 0")
+	}
+	if *f.bufferSize <= 0 {
+		return pipelines.Options{}, errors.New("buffer size must be greater than 0")
+	}
+	hash, err := domain.ParseHashFunc(*f.hash)
+	if err != nil {
+		return pipelines.Options{}, err
+	}
+	return pipelines.Options{
+		Workers:           *f.workers,
+		ParserWorkers:     *f.parserWorkers,
+		AggregatorWorkers: *f.aggregatorWorkers,
+		BufferSize:        *f.bufferSize,
+		Hash:              hash,
+	}, nil
+}
+
+// rejectFlags choose what happens to lines that fail to parse
+type rejectFlags struct {
+	onError       *string
+	quarantine    *string
+	maxRejectRate *float64
+}
+
+func addRejectFlags(fs *flag.FlagSet) *rejectFlags {
+	return &rejectFlags{
+		onError:       fs.String("on-error", domain.REJECT_SKIP.String(), "What to do with a malformed line: skip, fail or quarantine"),
+		quarantine:    fs.String("quarantine", "", "Write the malformed lines with their line number and reason to this file, implies -on-error quarantine"),
+		maxRejectRate: fs.Float64("max-reject-rate", 0, "Fail when more than this fraction of the lines is malformed, e.g. 0.01 (0 disables)"),
+	}
+}
+
+// apply sets the reject policy of opts, the quarantine file is opened by the caller
+func (f *rejectFlags) apply(opts *pipelines.Options) error {
+	onReject, err := domain.ParseOnReject(*f.onError)
+	if err != nil {
+		return err
+	}
+	if *f.quarantine != "" {
+		if onReject == domain.REJECT_FAIL {
+			return errors.New("-quarantine can not be combined with -on-error fail")
+		}
+		onReject = domain.REJECT_QUARANTINE
+	} else if onReject == domain.REJECT_QUARANTINE {
+		return errors.New("quarantine file is required: -quarantine <file_name>")
+	}
+	if *f.maxRejectRate < 0 || *f.maxRejectRate > 1 {
+		return errors.New("reject rate must be between 0 and 1")
+	}
+	opts.OnReject = onReject
+	opts.MaxRejectRate = *f.maxRejectRate
+	return nil
+}
+
+// filterFlags select the lines that are aggregated
+type filterFlags struct {
+	include *string
+	exclude *string
+	prefix  *string
+	match   *string
+	minTemp *string
+	maxTemp *string
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	return &filterFlags{
+		include: fs.String("include-stations", "", "Only aggregate the stations listed in this file, one per line"),
+		exclude: fs.String("exclude-stations", "", "Do not aggregate the stations listed in this file, one per line"),
+		prefix:  fs.String("prefix", "", "Only aggregate stations starting with one of these comma separated prefixes"),
+		match:   fs.String("match", "", "Only aggregate stations matching this regular expression"),
+		minTemp: fs.String("min-temp", "", "Only aggregate temperatures of at least this, e.g. -10.5"),
+		maxTemp: fs.String("max-temp", "", "Only aggregate temperatures of at most this, e.g. 40"),
+	}
+}
+
+// apply sets the filter of opts, it is left nil when no filter flag is given
+func (f *filterFlags) apply(opts *pipelines.Options) error {
+	filter := domain.NewFilter()
+	set := false
+	if *f.include != "" {
+		stations, err := readStations(*f.include)
+		if err != nil {
+			return err
+		}
+		filter.Include, set = stations, true
+	}
+	if *f.exclude != "" {
+		stations, err := readStations(*f.exclude)
+		if err != nil {
+			return err
+		}
+		filter.Exclude, set = stations, true
+	}
+	if *f.prefix != "" {
+		filter.Prefixes, set = strings.Split(*f.prefix, ","), true
+	}
+	if *f.match != "" {
+		pattern, err := regexp.Compile(*f.match)
+		if err != nil {
+			return fmt.Errorf("invalid -match: %w", err)
+		}
+		filter.Pattern, set = pattern, true
+	}
+	for _, bound := range []struct {
+		name  string
+		value string
+		t     *int
+	}{{"-min-temp", *f.minTemp, &filter.MinTemperature}, {"-max-temp", *f.maxTemp, &filter.MaxTemperature}} {
+		if bound.value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(bound.value, 64)
+		if err != nil || math.IsNaN(v) {
+			return fmt.Errorf("invalid %s %q, expected a temperature like -10.5", bound.name, bound.value)
+		}
+		t := int(math.Round(v * 10))
+		if t < domain.MIN_TEMPERATURE || t > domain.MAX_TEMPERATURE {
+			return fmt.Errorf("%s %s is out of range -99.9..99.9", bound.name, bound.value)
+		}
+		*bound.t, set = t, true
+	}
+	if filter.MinTemperature > filter.MaxTemperature {
+		return errors.New("-min-temp is higher than -max-temp")
+	}
+	if set {
+		opts.Filter = filter
+	}
+	return nil
+}
+
+func readStations(fname string) (map[string]bool, error) {
+	file, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return domain.ReadStations(file)
+}
+
+// MAX_REPORTED_STATIONS is the number of stations named in the join report unless verbose
+const MAX_REPORTED_STATIONS = 10
+
+// metadataFlags join a result with station metadata and roll it up
+type metadataFlags struct {
+	stations *string
+	group    *string
+	meta     domain.Metadata
+	levels   []string
+}
+
+func addMetadataFlags(fs *flag.FlagSet) *metadataFlags {
+	return &metadataFlags{
+		stations: fs.String("stations", "", "Join the stations with the metadata in this file, lines of name;country;region;lat;lon"),
+		group:    fs.String("group", "", "Roll the stations up by country, region or both outermost first, e.g. region,country (requires -stations)"),
+	}
+}
+
+// load reads the metadata file and checks the levels
+func (f *metadataFlags) load() error {
+	if *f.group != "" {
+		if *f.stations == "" {
+			return errors.New("-group requires a metadata file: -stations <file_name>")
+		}
+		levels, err := domain.ParseGroups(*f.group)
+		if err != nil {
+			return err
+		}
+		f.levels = levels
+	}
+	if *f.stations == "" {
+		return nil
+	}
+	file, err := os.Open(*f.stations)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if f.meta, err = domain.ReadMetadata(file); err != nil {
+		return fmt.Errorf("%s: %w", *f.stations, err)
+	}
+	return nil
+}
+
+// apply logs the join of res with the metadata and returns res grouped by the levels
+func (f *metadataFlags) apply(res *domain.Result, verbose bool) *domain.Result {
+	if f.meta == nil {
+		return res
+	}
+	join := res.Join(f.meta)
+	logStations := func(names []string, what string) {
+		if len(names) == 0 {
+			return
+		}
+		shown := names
+		if !verbose && len(shown) > MAX_REPORTED_STATIONS {
+			shown = shown[:MAX_REPORTED_STATIONS]
+		}
+		more := ""
+		if len(shown) < len(names) {
+			more = fmt.Sprintf(" and %d more", len(names)-len(shown))
+		}
+		log.Printf("%s: %d %s: %s%s", WARNING, len(names), what, strings.Join(shown, ", "), more)
+	}
+	logStations(join.Missing, "stations in the data have no metadata")
+	logStations(join.Unused, "stations of the metadata are not in the data")
+	return f.grouped(res)
+}
+
+// grouped returns res grouped by the levels, res itself without them
+func (f *metadataFlags) grouped(res *domain.Result) *domain.Result {
+	if f.levels == nil {
+		return res
+	}
+	return res.Group(f.meta, f.levels)
+}
+
+// outputFlags select the format and destination of a result
+type outputFlags struct {
+	format *string
+	out    *string
+}
+
+// addOutputFlags adds -format and -o, def is the format used when only -o is given
+func addOutputFlags(fs *flag.FlagSet, def string) *outputFlags {
+	return &outputFlags{
+		format: fs.String("format", def, "Result format: "+strings.Join(output.Names(), ", ")),
+		out:    fs.String("o", "", "Write the result to this file instead of stdout"),
+	}
+}
+
+func (f *outputFlags) check() error {
+	if *f.format == "" && *f.out != "" {
+		*f.format = output.FORMAT_CHALLENGE
+	}
+	if _, ok := output.Get(*f.format); *f.format != "" && !ok {
+		return fmt.Errorf("unknown format %q, expected one of: %s", *f.format, strings.Join(output.Names(), ", "))
+	}
+	return nil
+}
+
+// enabled tells if a format was selected
+func (f *outputFlags) enabled() bool {
+	return *f.format != ""
+}
+
+// write res in the selected format to -o or stdout
+func (f *outputFlags) write(res *domain.Result) error {
+	w, _ := output.Get(*f.format)
+	return f.writeTo(func(out io.Writer) error { return w.Write(out, res) })
+}
+
+// writeWindows writes every window of res in the selected format to -o or stdout
+func (f *outputFlags) writeWindows(res *domain.WindowedResult) error {
+	return f.writeTo(func(out io.Writer) error { return output.WriteWindows(out, *f.format, res) })
+}
+
+// writeSets writes every result of sets in the selected format to -o or stdout
+func (f *outputFlags) writeSets(sets output.Sets) error {
+	return f.writeTo(func(out io.Writer) error { return output.WriteSets(out, *f.format, sets) })
+}
+
+func (f *outputFlags) writeTo(write func(io.Writer) error) error {
+	if *f.out == "" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(*f.out)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// STDIN is the -f value that reads standard input
+const STDIN = "-"
+
+// inputFlags is the -f flag of the commands reading measurements, it can be repeated
+// and the arguments of the command are added to it
+type inputFlags []string
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	f := &inputFlags{}
+	fs.Var(f, "f", "File, glob or directory to read, - for stdin. Repeat it or pass more as arguments")
+	return f
+}
+
+func (f *inputFlags) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *inputFlags) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// paths of the files to read, globs and directories expanded
+func (f *inputFlags) paths(fs *flag.FlagSet) ([]string, error) {
+	paths := append(append([]string{}, *f...), fs.Args()...)
+	if len(paths) == 0 {
+		return nil, errors.New("filename is required: -f <file_name>")
+	}
+	for _, path := range paths {
+		if path == STDIN && len(paths) > 1 {
+			return nil, errors.New("stdin cannot be read together with files")
+		}
+	}
+	if paths[0] == STDIN {
+		return paths, nil
+	}
+	return pipelines.ExpandPaths(paths)
+}
+
+// openSources returns a source per path, decompressed when it is compressed. Commands that read
+// the sources more than once pass reread, stdin is then read into memory first.
+func openSources(paths []string, reread bool, opts pipelines.Options) ([]pipelines.Source, error) {
+	// concurrent files share the workers, see pipelines.RunFiles
+	workers := max(1, opts.Workers/min(len(paths), opts.Workers))
+	sources := make([]pipelines.Source, len(paths))
+	for i, path := range paths {
+		var src pipelines.Source
+		switch {
+		case path != STDIN:
+			src = pipelines.FileSource(path)
+		case !reread:
+			src = pipelines.StdinSource()
+		default:
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, err
+			}
+			src = pipelines.BytesSource("stdin", data)
+		}
+		sources[i] = pipelines.Decompressed(src, workers)
+	}
+	return sources, nil
+}
+
+// parseModes splits a comma separated list of pipelines, empty means all
+func parseModes(list string) ([]string, error) {
+	if list == "" {
+		return pipelines.Names(), nil
+	}
+	modes := strings.Split(list, ",")
+	for i, m := range modes {
+		modes[i] = strings.TrimSpace(m)
+		if _, ok := pipelines.Get(modes[i]); !ok {
+			return nil, fmt.Errorf("unknown mode %q, expected one of: %s", modes[i], strings.Join(pipelines.Names(), ", "))
+		}
+	}
+	return modes, nil
+}
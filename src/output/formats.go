@@ -0,0 +1,201 @@
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/brcgo/src/domain"
+)
+
+// jsonStation is a station in the JSON formats, temperatures rounded to one decimal
+type jsonStation struct {
+	Station string      `json:"station"`
+	Min     json.Number `json:"min"`
+	Mean    json.Number `json:"mean"`
+	Max     json.Number `json:"max"`
+	Count   int         `json:"count"`
+	*jsonStats
+}
+
+// jsonStats are the extended statistics of a station, in the order of statsColumns
+type jsonStats struct {
+	StdDev   json.Number `json:"stddev"`
+	Variance json.Number `json:"variance"`
+	Median   json.Number `json:"median"`
+	P90      json.Number `json:"p90"`
+	P95      json.Number `json:"p95"`
+	P99      json.Number `json:"p99"`
+	Mode     json.Number `json:"mode"`
+}
+
+func toJSON(r Row) jsonStation {
+	station := jsonStation{
+		Station: r.Station,
+		Min:     json.Number(domain.FormatTenths(r.Min)),
+		Mean:    json.Number(domain.FormatTenths(r.Mean)),
+		Max:     json.Number(domain.FormatTenths(r.Max)),
+		Count:   r.Count,
+	}
+	if r.Stats != nil {
+		v := statsValues(r)
+		station.jsonStats = &jsonStats{
+			StdDev:   json.Number(v[0]),
+			Variance: json.Number(v[1]),
+			Median:   json.Number(v[2]),
+			P90:      json.Number(v[3]),
+			P95:      json.Number(v[4]),
+			P99:      json.Number(v[5]),
+			Mode:     json.Number(v[6]),
+		}
+	}
+	return station
+}
+
+// WriteJSON writes a single document with the stations and the totals of the run
+func WriteJSON(w io.Writer, res *domain.Result) error {
+	rows := Rows(res)
+	doc := struct {
+		Stations []jsonStation `json:"stations"`
+		Lines    int64         `json:"lines"`
+		Bytes    int64         `json:"bytes"`
+		Errors   int64         `json:"errors"`
+		Filtered int64         `json:"filtered,omitempty"`
+		Partial  bool          `json:"partial,omitempty"`
+		Offset   int64         `json:"offset,omitempty"`
+	}{
+		Stations: make([]jsonStation, len(rows)),
+		Lines:    res.Lines,
+		Bytes:    res.Bytes,
+		Errors:   res.Errors,
+		Filtered: res.Filtered,
+		Partial:  res.Partial,
+	}
+	for i, r := range rows {
+		doc.Stations[i] = toJSON(r)
+	}
+	if res.Partial && !res.Merged {
+		doc.Offset = res.Offset
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// WriteNDJSON writes one JSON object per station and line
+func WriteNDJSON(w io.Writer, res *domain.Result) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, r := range Rows(res) {
+		if err := enc.Encode(toJSON(r)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteCSV writes a header and one record per station
+func WriteCSV(w io.Writer, res *domain.Result) error {
+	rows := Rows(res)
+	stats := hasStats(rows)
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader(stats))
+	for _, r := range rows {
+		cw.Write(csvRecord(r, stats))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvHeader(stats bool) []string {
+	header := []string{"station", "min", "mean", "max", "count"}
+	if stats {
+		header = append(header, statsColumns...)
+	}
+	return header
+}
+
+func csvRecord(r Row, stats bool) []string {
+	record := []string{r.Station, domain.FormatTenths(r.Min), domain.FormatTenths(r.Mean), domain.FormatTenths(r.Max), strconv.Itoa(r.Count)}
+	if stats {
+		record = append(record, statsValues(r)...)
+	}
+	return record
+}
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+
+// WriteMarkdown writes a table with one row per station
+func WriteMarkdown(w io.Writer, res *domain.Result) error {
+	rows := Rows(res)
+	stats := hasStats(rows)
+	bw := bufio.NewWriter(w)
+	header, align := "| Station | Min | Mean | Max | Count |", "|---|--:|--:|--:|--:|"
+	if stats {
+		header += " " + strings.Join(statsColumns, " | ") + " |"
+		align += strings.Repeat("--:|", len(statsColumns))
+	}
+	fmt.Fprintln(bw, header)
+	fmt.Fprintln(bw, align)
+	for _, r := range rows {
+		fmt.Fprintf(bw, "| %s | %s | %s | %s | %d |",
+			markdownEscaper.Replace(r.Station), domain.FormatTenths(r.Min), domain.FormatTenths(r.Mean), domain.FormatTenths(r.Max), r.Count)
+		if stats {
+			fmt.Fprintf(bw, " %s |", strings.Join(statsValues(r), " | "))
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes the text exposition format, one gauge per statistic labelled by station
+func WritePrometheus(w io.Writer, res *domain.Result) error {
+	rows := Rows(res)
+	bw := bufio.NewWriter(w)
+	metric := func(name, typ, help string, value func(Row) string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, r := range rows {
+			if v := value(r); v != "" {
+				fmt.Fprintf(bw, "%s{station=\"%s\"} %s\n", name, labelEscaper.Replace(r.Station), v)
+			}
+		}
+	}
+	metric("brc_temperature_min_celsius", "gauge", "Lowest temperature of the station.",
+		func(r Row) string { return domain.FormatTenths(r.Min) })
+	metric("brc_temperature_mean_celsius", "gauge", "Mean temperature of the station.",
+		func(r Row) string { return domain.FormatTenths(r.Mean) })
+	metric("brc_temperature_max_celsius", "gauge", "Highest temperature of the station.",
+		func(r Row) string { return domain.FormatTenths(r.Max) })
+	metric("brc_measurements_total", "counter", "Measurements of the station.",
+		func(r Row) string { return strconv.Itoa(r.Count) })
+	if hasStats(rows) {
+		metric("brc_temperature_stddev_celsius", "gauge", "Standard deviation of the temperatures of the station.",
+			func(r Row) string { return statsValues(r)[0] })
+		const name = "brc_temperature_quantile_celsius"
+		fmt.Fprintf(bw, "# HELP %s Temperature quantiles of the station.\n# TYPE %s gauge\n", name, name)
+		for _, r := range rows {
+			if r.Stats == nil {
+				continue
+			}
+			for _, q := range []float64{50, 90, 95, 99} {
+				fmt.Fprintf(bw, "%s{station=\"%s\",quantile=\"%g\"} %s\n",
+					name, labelEscaper.Replace(r.Station), q/100, domain.FormatTenths(r.Stats.Percentile(q)))
+			}
+		}
+	}
+
+	total := func(name, help string, value int64) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+	}
+	total("brc_lines_total", "Lines read.", res.Lines)
+	total("brc_bytes_total", "Bytes read.", res.Bytes)
+	total("brc_errors_total", "Lines rejected.", res.Errors)
+	total("brc_filtered_total", "Lines filtered out.", res.Filtered)
+	return bw.Flush()
+}
@@ -0,0 +1,128 @@
+// Package output writes results in the formats selectable with -format
+package output
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/brcgo/src/domain"
+)
+
+// Writer writes a result in one format
+type Writer interface {
+	Write(w io.Writer, res *domain.Result) error
+}
+
+// WriterFunc adapts a function to the Writer interface
+type WriterFunc func(w io.Writer, res *domain.Result) error
+
+func (f WriterFunc) Write(w io.Writer, res *domain.Result) error {
+	return f(w, res)
+}
+
+const (
+	FORMAT_CHALLENGE  = "challenge"
+	FORMAT_JSON       = "json"
+	FORMAT_NDJSON     = "ndjson"
+	FORMAT_CSV        = "csv"
+	FORMAT_MARKDOWN   = "markdown"
+	FORMAT_PROMETHEUS = "prometheus"
+)
+
+var (
+	names    []string
+	registry = make(map[string]Writer)
+)
+
+func init() {
+	Register(FORMAT_CHALLENGE, WriterFunc(WriteChallenge))
+	Register(FORMAT_JSON, WriterFunc(WriteJSON))
+	Register(FORMAT_NDJSON, WriterFunc(WriteNDJSON))
+	Register(FORMAT_CSV, WriterFunc(WriteCSV))
+	Register(FORMAT_MARKDOWN, WriterFunc(WriteMarkdown))
+	Register(FORMAT_PROMETHEUS, WriterFunc(WritePrometheus))
+}
+
+func Register(name string, w Writer) {
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("output format %q already registered", name))
+	}
+	names = append(names, name)
+	registry[name] = w
+}
+
+func Get(name string) (Writer, bool) {
+	w, ok := registry[name]
+	return w, ok
+}
+
+// Names of the registered formats in registration order
+func Names() []string {
+	return append([]string(nil), names...)
+}
+
+// Row is the aggregate of one station as it is written, temperatures in tenths of a degree
+// with the mean rounded like the challenge
+type Row struct {
+	Station string
+	Min     int
+	Mean    int
+	Max     int
+	Count   int
+	Stats   *domain.Stats // nil unless extended statistics were computed
+}
+
+// Rows of res sorted by station name, or in the order of its ranking
+func Rows(res *domain.Result) []Row {
+	keys := res.Keys()
+	rows := make([]Row, len(keys))
+	for i, k := range keys {
+		s := res.Stations[k]
+		rows[i] = Row{
+			Station: k,
+			Min:     s.Min,
+			Mean:    s.Mean(),
+			Max:     s.Max,
+			Count:   s.Count,
+			Stats:   s.Stats,
+		}
+	}
+	return rows
+}
+
+// hasStats reports whether any of the rows has extended statistics
+func hasStats(rows []Row) bool {
+	for _, r := range rows {
+		if r.Stats != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// statsColumns are the names of the extended statistics in the table formats
+var statsColumns = []string{"stddev", "variance", "median", "p90", "p95", "p99", "mode"}
+
+// statsValues formats the extended statistics of r in the order of statsColumns, empty without them
+func statsValues(r Row) []string {
+	s := r.Stats
+	if s == nil {
+		return make([]string, len(statsColumns))
+	}
+	return []string{
+		strconv.FormatFloat(s.StdDev()/10, 'f', 2, 64),
+		strconv.FormatFloat(s.Variance()/100, 'f', 2, 64),
+		domain.FormatTenths(s.Median()),
+		domain.FormatTenths(s.Percentile(90)),
+		domain.FormatTenths(s.Percentile(95)),
+		domain.FormatTenths(s.Percentile(99)),
+		domain.FormatTenths(s.Mode()),
+	}
+}
+
+// WriteChallenge writes {<station>=<min>/<mean>/<max>, ...}
+func WriteChallenge(w io.Writer, res *domain.Result) error {
+	_, err := fmt.Fprintln(w, res.String())
+	return err
+}
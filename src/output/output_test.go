@@ -0,0 +1,122 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brcgo/src/domain"
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func testResult() *domain.Result {
+	res := domain.NewResult()
+	res.Add("Hamburg", 120)
+	res.Add("Hamburg", -34)
+	res.Add(`St. "John|s", NL`, 5)
+	res.Lines = 3
+	return res
+}
+
+func write(t *testing.T, format string) string {
+	t.Helper()
+	w, ok := Get(format)
+	AssertTrue(t, ok)
+	var buf bytes.Buffer
+	if err := w.Write(&buf, testResult()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestFormats(t *testing.T) {
+
+	t.Run("Challenge", func(t *testing.T) {
+		AssertEqual(t, write(t, FORMAT_CHALLENGE), `{Hamburg=-3.4/4.3/12.0, St. "John|s", NL=0.5/0.5/0.5}`+"\n")
+	})
+
+	t.Run("JSON documents parse", func(t *testing.T) {
+		var doc struct {
+			Stations []struct {
+				Station string
+				Mean    float64
+			}
+			Lines int64
+		}
+		AssertTrue(t, json.Unmarshal([]byte(write(t, FORMAT_JSON)), &doc) == nil)
+		AssertEqual(t, len(doc.Stations), 2)
+		AssertEqual(t, doc.Stations[0].Mean, 4.3)
+		AssertEqual(t, doc.Lines, int64(3))
+
+		lines := strings.Split(strings.TrimSpace(write(t, FORMAT_NDJSON)), "\n")
+		AssertEqual(t, len(lines), 2)
+		var station jsonStation
+		AssertTrue(t, json.Unmarshal([]byte(lines[1]), &station) == nil)
+		AssertEqual(t, station.Station, `St. "John|s", NL`)
+	})
+
+	t.Run("Station names are escaped", func(t *testing.T) {
+		AssertTrue(t, strings.Contains(write(t, FORMAT_CSV), `"St. ""John|s"", NL",0.5,0.5,0.5,1`))
+		AssertTrue(t, strings.Contains(write(t, FORMAT_MARKDOWN), `| St. "John\|s", NL | 0.5 | 0.5 | 0.5 | 1 |`))
+		AssertTrue(t, strings.Contains(write(t, FORMAT_PROMETHEUS), `brc_temperature_max_celsius{station="St. \"John|s\", NL"} 0.5`))
+	})
+}
+
+func TestWindows(t *testing.T) {
+	res := domain.NewWindowedResult(domain.WINDOW_DAY)
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	res.Result(day).Add("Oslo", -34)
+	res.Result(day.AddDate(0, 0, -1)).Add("Hamburg", 120)
+	res.Total.Lines = 2
+
+	var buf bytes.Buffer
+	AssertTrue(t, WriteWindows(&buf, FORMAT_CSV, res) == nil)
+	AssertEqual(t, buf.String(), "start,station,min,mean,max,count\n"+
+		"2024-01-01T00:00:00Z,Hamburg,12.0,12.0,12.0,1\n2024-01-02T00:00:00Z,Oslo,-3.4,-3.4,-3.4,1\n")
+
+	buf.Reset()
+	AssertTrue(t, WriteWindows(&buf, FORMAT_JSON, res) == nil)
+	var doc struct {
+		Window  string
+		Windows []struct {
+			Start    string
+			Stations []struct{ Station string }
+		}
+		Lines int64
+	}
+	AssertTrue(t, json.Unmarshal(buf.Bytes(), &doc) == nil)
+	AssertEqual(t, doc.Window, domain.WINDOW_DAY)
+	AssertEqual(t, len(doc.Windows), 2)
+	AssertEqual(t, doc.Windows[1].Stations[0].Station, "Oslo")
+	AssertEqual(t, doc.Lines, int64(2))
+
+	AssertTrue(t, !SupportsSets(FORMAT_PROMETHEUS))
+	AssertTrue(t, WriteWindows(&buf, FORMAT_PROMETHEUS, res) != nil)
+}
+
+func TestPartialStats(t *testing.T) {
+	res := testResult()
+	stats := domain.NewStats()
+	stats.Add(50)
+	res.Stations[`St. "John|s", NL`].Stats = stats
+
+	var buf bytes.Buffer
+	AssertTrue(t, WriteCSV(&buf, res) == nil)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	AssertEqual(t, lines[0], "station,min,mean,max,count,stddev,variance,median,p90,p95,p99,mode")
+	AssertEqual(t, lines[1], "Hamburg,-3.4,4.3,12.0,2,,,,,,,")
+	AssertTrue(t, strings.HasSuffix(lines[2], ",1,0.00,0.00,5.0,5.0,5.0,5.0,5.0"))
+}
+
+func TestFileSets(t *testing.T) {
+	sets := Sets{Kind: SETS_FILES, Labels: []string{"a.txt", "b.txt"}, Results: []*domain.Result{testResult(), domain.NewResult()}, Total: testResult()}
+	var buf bytes.Buffer
+	AssertTrue(t, WriteSets(&buf, FORMAT_CHALLENGE, sets) == nil)
+	AssertEqual(t, buf.String(), "a.txt "+write(t, FORMAT_CHALLENGE)+"b.txt {}\n")
+
+	buf.Reset()
+	AssertTrue(t, WriteSets(&buf, FORMAT_NDJSON, sets) == nil)
+	AssertTrue(t, strings.HasPrefix(buf.String(), `{"file":"a.txt","station":"Hamburg",`))
+}
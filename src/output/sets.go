@@ -0,0 +1,189 @@
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/brcgo/src/domain"
+)
+
+// Kinds of result sets
+const (
+	SETS_WINDOWS = "windows" // the windows of a run, labelled by their start
+	SETS_FILES   = "files"   // the results of the files of a run, labelled by file name
+)
+
+// Sets are results written together, one result set per label
+type Sets struct {
+	Kind    string // SETS_WINDOWS or SETS_FILES
+	Window  string // with SETS_WINDOWS, the size of the windows
+	Labels  []string
+	Results []*domain.Result // the result of each label
+	Total   *domain.Result   // the counts of the run
+}
+
+// labelColumn is the name of the label in the table and JSON formats
+func (s Sets) labelColumn() string {
+	if s.Kind == SETS_WINDOWS {
+		return "start"
+	}
+	return "file"
+}
+
+// setFormats are the formats that write result sets
+var setFormats = map[string]func(io.Writer, Sets) error{
+	FORMAT_CHALLENGE: writeChallengeSets,
+	FORMAT_JSON:      writeJSONSets,
+	FORMAT_NDJSON:    writeNDJSONSets,
+	FORMAT_CSV:       writeCSVSets,
+	FORMAT_MARKDOWN:  writeMarkdownSets,
+}
+
+// SupportsSets reports whether the format writes result sets, windows or files
+func SupportsSets(format string) bool {
+	return setFormats[format] != nil
+}
+
+// WriteSets writes every result of sets in format, in the order of the labels
+func WriteSets(w io.Writer, format string, sets Sets) error {
+	write := setFormats[format]
+	if write == nil {
+		return fmt.Errorf("format %s does not support %s", format, sets.Kind)
+	}
+	return write(w, sets)
+}
+
+// WriteWindows writes every window of res in format, windows in time order
+func WriteWindows(w io.Writer, format string, res *domain.WindowedResult) error {
+	sets := Sets{Kind: SETS_WINDOWS, Window: res.Window, Total: res.Total}
+	for _, start := range res.Starts() {
+		sets.Labels = append(sets.Labels, start.Format(time.RFC3339))
+		sets.Results = append(sets.Results, res.Windows[start])
+	}
+	return WriteSets(w, format, sets)
+}
+
+// writeChallengeSets writes <label> {<station>=<min>/<mean>/<max>, ...} per result
+func writeChallengeSets(w io.Writer, sets Sets) error {
+	bw := bufio.NewWriter(w)
+	for i, res := range sets.Results {
+		fmt.Fprintf(bw, "%s %s\n", sets.Labels[i], res)
+	}
+	return bw.Flush()
+}
+
+// jsonSet is a result of the JSON format, the label under the name of labelColumn
+type jsonSet struct {
+	Start    string        `json:"start,omitempty"`
+	File     string        `json:"file,omitempty"`
+	Stations []jsonStation `json:"stations"`
+}
+
+// writeJSONSets writes a single document with the stations of every result and the totals of the run
+func writeJSONSets(w io.Writer, sets Sets) error {
+	total := sets.Total
+	list := []jsonSet{}
+	doc := struct {
+		Window   string     `json:"window,omitempty"`
+		Windows  *[]jsonSet `json:"windows,omitempty"`
+		Files    *[]jsonSet `json:"files,omitempty"`
+		Lines    int64      `json:"lines"`
+		Bytes    int64      `json:"bytes"`
+		Errors   int64      `json:"errors"`
+		Filtered int64      `json:"filtered,omitempty"`
+		Partial  bool       `json:"partial,omitempty"`
+		Offset   int64      `json:"offset,omitempty"`
+	}{
+		Window:   sets.Window,
+		Lines:    total.Lines,
+		Bytes:    total.Bytes,
+		Errors:   total.Errors,
+		Filtered: total.Filtered,
+		Partial:  total.Partial,
+	}
+	if sets.Kind == SETS_WINDOWS {
+		doc.Windows = &list
+	} else {
+		doc.Files = &list
+	}
+	for i, res := range sets.Results {
+		rows := Rows(res)
+		set := jsonSet{Stations: make([]jsonStation, len(rows))}
+		if sets.Kind == SETS_WINDOWS {
+			set.Start = sets.Labels[i]
+		} else {
+			set.File = sets.Labels[i]
+		}
+		for j, r := range rows {
+			set.Stations[j] = toJSON(r)
+		}
+		list = append(list, set)
+	}
+	if total.Partial && !total.Merged {
+		doc.Offset = total.Offset
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// writeNDJSONSets writes one JSON object per result and station with its label
+func writeNDJSONSets(w io.Writer, sets Sets) error {
+	type labelled struct {
+		Start string `json:"start,omitempty"`
+		File  string `json:"file,omitempty"`
+		jsonStation
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i, res := range sets.Results {
+		for _, r := range Rows(res) {
+			station := labelled{jsonStation: toJSON(r)}
+			if sets.Kind == SETS_WINDOWS {
+				station.Start = sets.Labels[i]
+			} else {
+				station.File = sets.Labels[i]
+			}
+			if err := enc.Encode(station); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// writeCSVSets writes a header and one record per result and station, the label first
+func writeCSVSets(w io.Writer, sets Sets) error {
+	stats := false
+	for _, res := range sets.Results {
+		stats = stats || hasStats(Rows(res))
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{sets.labelColumn()}, csvHeader(stats)...))
+	for i, res := range sets.Results {
+		for _, r := range Rows(res) {
+			cw.Write(append([]string{sets.Labels[i]}, csvRecord(r, stats)...))
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeMarkdownSets writes a heading with the label and a table per result
+func writeMarkdownSets(w io.Writer, sets Sets) error {
+	for i, res := range sets.Results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "## %s\n\n", strings.TrimSpace(markdownEscaper.Replace(sets.Labels[i])))
+		if err := WriteMarkdown(w, res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package pipelines
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Compression formats, detected by the magic bytes at the start of the input
+const (
+	COMPRESSION_NONE  = "none"
+	COMPRESSION_GZIP  = "gzip"
+	COMPRESSION_BZIP2 = "bzip2"
+	COMPRESSION_ZLIB  = "zlib"
+)
+
+// HEADER_SIZE is the number of bytes read to detect the compression
+const HEADER_SIZE = 512
+
+var (
+	bzip2Block = []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59} // pi, starts a block
+	bzip2End   = []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90} // sqrt(pi), ends an empty stream
+)
+
+// DetectCompression returns the compression of an input starting with header.
+// A zlib header is short enough to occur in text, so it is only accepted when header inflates.
+func DetectCompression(header []byte) string {
+	switch {
+	case len(header) >= 3 && header[0] == 0x1f && header[1] == 0x8b && header[2] == 8:
+		return COMPRESSION_GZIP
+	case len(header) >= 10 && bytes.HasPrefix(header, []byte("BZh")) && header[3] >= '1' && header[3] <= '9' &&
+		(bytes.Equal(header[4:10], bzip2Block) || bytes.Equal(header[4:10], bzip2End)):
+		return COMPRESSION_BZIP2
+	case isZlib(header):
+		return COMPRESSION_ZLIB
+	}
+	return COMPRESSION_NONE
+}
+
+// isZlib reports whether header starts a zlib stream: a valid zlib header followed by deflate data
+// that inflates without error to text, either to its end with a matching checksum or up to the end
+// of header. Text can start with a valid zlib header and inflate a few bytes before it fails.
+func isZlib(header []byte) bool {
+	if len(header) < 2 || header[0]&0x0f != 8 || header[0]>>4 > 7 || header[1]&0x20 != 0 ||
+		(uint16(header[0])<<8|uint16(header[1]))%31 != 0 {
+		return false
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(header))
+	if err != nil {
+		return false
+	}
+	sample, err := io.ReadAll(zr)
+	if err != nil && (err != io.ErrUnexpectedEOF || len(header) < HEADER_SIZE) {
+		return false
+	}
+	for _, b := range sample {
+		if b < ' ' && b != '\n' && b != '\r' && b != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+type decompressedSource struct {
+	src     Source
+	workers int
+
+	mu          sync.Mutex
+	compression string // of the last Open, empty before
+}
+
+// Decompressed reads src through gzip, bzip2 or zlib when it starts with their magic bytes and as is otherwise.
+// A multi-member gzip file, like those of bgzip or concatenated .gz files, has up to workers members
+// decoded concurrently. Offsets and line numbers are those of the decompressed input.
+func Decompressed(src Source, workers int) Source {
+	return &decompressedSource{src: src, workers: workers}
+}
+
+func (s *decompressedSource) Name() string {
+	return s.src.Name()
+}
+
+// Size of src when it is not compressed, UNKNOWN_SIZE when it is or when that is not known before reading it
+func (s *decompressedSource) Size() (int64, error) {
+	s.mu.Lock()
+	compression := s.compression
+	s.mu.Unlock()
+	if compression == "" {
+		file, ok := s.src.(fileSource)
+		if !ok {
+			return UNKNOWN_SIZE, nil
+		}
+		compression = fileCompression(file.path)
+	}
+	if compression != COMPRESSION_NONE {
+		return UNKNOWN_SIZE, nil
+	}
+	return s.src.Size()
+}
+
+func fileCompression(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return COMPRESSION_NONE // reported by Open
+	}
+	defer file.Close()
+	header := make([]byte, HEADER_SIZE)
+	n, _ := io.ReadFull(file, header)
+	return DetectCompression(header[:n])
+}
+
+func (s *decompressedSource) Open() (io.ReadCloser, error) {
+	r, err := s.src.Open()
+	if err != nil {
+		return nil, err
+	}
+	header, rest, err := peekHeader(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	compression := DetectCompression(header)
+	s.mu.Lock()
+	s.compression = compression
+	s.mu.Unlock()
+
+	var decompressed io.Reader
+	switch compression {
+	case COMPRESSION_NONE:
+		if rest == nil {
+			return r, nil // unchanged, so files can still be mapped
+		}
+		return readCloser{rest, r.Close}, nil
+	case COMPRESSION_GZIP:
+		if ra, size, ok := readerAt(r); ok && rest == nil && s.workers > 1 {
+			members := newGzipMembers(ra, size, s.workers)
+			return readCloser{members, func() error { return errors.Join(members.Close(), r.Close()) }}, nil
+		}
+		decompressed, err = gzip.NewReader(orReader(rest, r))
+	case COMPRESSION_BZIP2:
+		decompressed = bzip2.NewReader(orReader(rest, r))
+	case COMPRESSION_ZLIB:
+		decompressed, err = zlib.NewReader(orReader(rest, r))
+	}
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return readCloser{decompressed, func() error {
+		if closer, ok := decompressed.(io.Closer); ok {
+			return errors.Join(closer.Close(), r.Close())
+		}
+		return r.Close()
+	}}, nil
+}
+
+// peekHeader returns the first HEADER_SIZE bytes of r. When they cannot be read with ReadAt
+// rest is a reader that starts with them, otherwise it is nil and r is still at its start.
+func peekHeader(r io.Reader) (header []byte, rest io.Reader, err error) {
+	header = make([]byte, HEADER_SIZE)
+	if ra, _, ok := readerAt(r); ok {
+		n, err := ra.ReadAt(header, 0)
+		if err == nil || err == io.EOF {
+			return header[:n], nil, nil
+		}
+	}
+	buffered := bufio.NewReaderSize(r, HEADER_SIZE)
+	header, err = buffered.Peek(HEADER_SIZE)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, err
+	}
+	return header, buffered, nil
+}
+
+// readerAt returns r as an io.ReaderAt with its size when r is a regular file or a reader with a size
+func readerAt(r io.Reader) (io.ReaderAt, int64, bool) {
+	switch r := r.(type) {
+	case *os.File:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil, 0, false
+		}
+		return r, info.Size(), true
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return r, r.Size(), true
+	}
+	return nil, 0, false
+}
+
+func orReader(rest, r io.Reader) io.Reader {
+	if rest != nil {
+		return rest
+	}
+	return r
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error {
+	return r.close()
+}
@@ -0,0 +1,173 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/brcgo/src/domain"
+)
+
+// ExpandPaths returns the files named by paths, globs and directories, which are walked recursively.
+// Files are returned cleaned in the order of paths, the files of a glob or directory sorted, without
+// duplicates: a file named by several paths, like ./a.txt and a.txt, is returned once.
+func ExpandPaths(paths []string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	add := func(file string) error {
+		file = filepath.Clean(file)
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		if !seen[abs] {
+			seen[abs] = true
+			files = append(files, file)
+		}
+		return nil
+	}
+
+	for _, path := range paths {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		if matches == nil {
+			matches = []string{path} // reported as missing by os.Stat
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				if err := add(match); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			err = filepath.WalkDir(match, func(file string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() {
+					return add(file)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, errors.New("no files to read")
+	}
+	return files, nil
+}
+
+// FileResult is the result of one source of RunFiles
+type FileResult struct {
+	Name   string
+	Result *domain.Result // nil when the source was not read
+	Err    error
+}
+
+// RunFiles runs p on each source and merges their results. Up to opts.Workers sources are read
+// concurrently, each with an equal share of the workers. Rejected lines name their source, the reject
+// budget applies to the lines of all sources. The first failing source stops the others, when ctx is
+// cancelled the result merges the partial results of the sources started so far.
+func RunFiles(ctx context.Context, p Pipeline, sources []Source, opts Options) (*domain.Result, []FileResult, error) {
+	startTime := time.Now()
+	opts = opts.withDefaults().withRejects()
+	concurrency := max(1, min(len(sources), opts.Workers))
+	fileOpts := opts
+	fileOpts.Workers = max(1, opts.Workers/concurrency)
+	fileOpts.ParserWorkers = max(1, opts.ParserWorkers/concurrency)
+	fileOpts.AggregatorWorkers = max(1, opts.AggregatorWorkers/concurrency)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// errors caused by the cancellation of ctx leave a partial result, others fail the run
+	failed := func(f FileResult) bool {
+		return f.Err != nil && (ctx.Err() == nil || !errors.Is(f.Err, ctx.Err()))
+	}
+
+	files := make([]FileResult, len(sources))
+	for i, src := range sources {
+		files[i].Name = src.Name() // also of the sources not started when ctx is cancelled
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				files[i] = runFile(ctx, p, sources[i], fileOpts)
+				if failed(files[i]) {
+					cancel(files[i].Err)
+				}
+			}
+		}()
+	}
+	for i := range sources {
+		if ctx.Err() != nil {
+			break
+		}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	processed := time.Now()
+
+	if opts.quarantine != nil {
+		if err := opts.quarantine.Flush(); err != nil {
+			return nil, files, err
+		}
+	}
+	for _, f := range files {
+		if failed(f) {
+			return nil, files, f.Err
+		}
+	}
+
+	res := domain.NewResult()
+	res.Merged = len(sources) > 1
+	for _, f := range files {
+		if f.Result != nil {
+			res.Merge(f.Result)
+		}
+	}
+	res.Timings.Started = startTime
+	res.Timings.Process = processed.Sub(startTime)
+	res.Timings.Merge = time.Since(processed)
+	res.Timings.Total = time.Since(startTime)
+	if err := domain.CheckRejectRate(res, opts.MaxRejectRate); err != nil {
+		return nil, files, err
+	}
+	if err := ctx.Err(); err != nil {
+		res.Partial = true
+		return res, files, err
+	}
+	return res, files, nil
+}
+
+// runFile runs p on src, its rejected lines are named after src
+func runFile(ctx context.Context, p Pipeline, src Source, opts Options) FileResult {
+	opts.file = src.Name()
+	res, err := p.Run(ctx, src, opts)
+	var perr *domain.ParseError
+	if err != nil && !errors.As(err, &perr) && !errors.Is(err, domain.ErrRejectBudget) {
+		err = fmt.Errorf("%s: %w", src.Name(), err)
+	}
+	return FileResult{Name: src.Name(), Result: res, Err: err}
+}
@@ -0,0 +1,190 @@
+package pipelines
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"sync"
+)
+
+// MAX_MEMBER_SIZE is the decompressed size of a gzip member above which the members that follow
+// are decoded sequentially, so a file with one large member is not held in memory
+const MAX_MEMBER_SIZE = 16 * 1024 * 1024
+
+var (
+	errMemberTooLarge = errors.New("gzip member too large")
+	errStopped        = errors.New("gzip decoding stopped")
+)
+
+// gzipHeader is the start of every gzip member: magic bytes and deflate
+var gzipHeader = []byte{0x1f, 0x8b, 0x08}
+
+type member struct {
+	data []byte
+	end  int64 // offset of the byte following the member
+	err  error
+}
+
+// gzipMembers decodes the members of a gzip file concurrently and reads them in order.
+// Member boundaries are only known after decoding, so every occurrence of the gzip header
+// is decoded speculatively, those that are not the start of a member are discarded.
+type gzipMembers struct {
+	r       io.ReaderAt
+	size    int64
+	workers int
+
+	candidates []int64 // offsets of the gzip headers not yet decoded
+	scanned    bool
+	pending    map[int64]chan member
+	next       int64  // offset of the next member
+	data       []byte // decoded data of the current member not read yet
+	sequential io.ReadCloser
+
+	stop     chan struct{} // closed by Close, stops the members being decoded
+	decoders sync.WaitGroup
+}
+
+func newGzipMembers(r io.ReaderAt, size int64, workers int) *gzipMembers {
+	return &gzipMembers{r: r, size: size, workers: workers, pending: make(map[int64]chan member), stop: make(chan struct{})}
+}
+
+func (g *gzipMembers) Read(p []byte) (int, error) {
+	for len(g.data) == 0 {
+		if g.sequential != nil {
+			return g.sequential.Read(p)
+		}
+		if g.next >= g.size {
+			return 0, io.EOF
+		}
+		if !g.scanned {
+			candidates, err := findHeaders(g.r, g.size)
+			if err != nil {
+				return 0, err
+			}
+			g.candidates, g.scanned = candidates, true
+		}
+
+		// headers inside the member just read were false starts
+		for offset := range g.pending {
+			if offset < g.next {
+				delete(g.pending, offset)
+			}
+		}
+		for len(g.candidates) > 0 && g.candidates[0] < g.next {
+			g.candidates = g.candidates[1:]
+		}
+		for len(g.pending) < g.workers && len(g.candidates) > 0 {
+			offset := g.candidates[0]
+			g.candidates = g.candidates[1:]
+			done := make(chan member, 1)
+			g.pending[offset] = done
+			g.decoders.Add(1)
+			go func() {
+				defer g.decoders.Done()
+				done <- decodeMember(g.r, offset, g.size, g.stop)
+			}()
+		}
+
+		done, ok := g.pending[g.next]
+		if !ok {
+			return 0, gzip.ErrHeader
+		}
+		delete(g.pending, g.next)
+		m := <-done
+		if m.err == errMemberTooLarge {
+			zr, err := gzip.NewReader(bufio.NewReader(io.NewSectionReader(g.r, g.next, g.size-g.next)))
+			if err != nil {
+				return 0, err
+			}
+			g.sequential = zr
+			continue
+		}
+		if m.err != nil {
+			return 0, m.err
+		}
+		g.data, g.next = m.data, m.end
+	}
+	n := copy(p, g.data)
+	g.data = g.data[n:]
+	return n, nil
+}
+
+// Close stops decoding and waits for the members being decoded, r is not read after it returns
+func (g *gzipMembers) Close() error {
+	select {
+	case <-g.stop:
+	default:
+		close(g.stop)
+	}
+	g.decoders.Wait()
+	g.pending, g.data = nil, nil
+	if g.sequential != nil {
+		return g.sequential.Close()
+	}
+	return nil
+}
+
+// stoppable fails reads once stop is closed
+type stoppable struct {
+	r    io.Reader
+	stop <-chan struct{}
+}
+
+func (s stoppable) Read(p []byte) (int, error) {
+	select {
+	case <-s.stop:
+		return 0, errStopped
+	default:
+		return s.r.Read(p)
+	}
+}
+
+// decodeMember decodes the gzip member at offset, until stop is closed
+func decodeMember(r io.ReaderAt, offset, size int64, stop <-chan struct{}) member {
+	section := io.NewSectionReader(r, offset, size-offset)
+	buffered := bufio.NewReader(stoppable{section, stop})
+	zr, err := gzip.NewReader(buffered)
+	if err != nil {
+		return member{err: err}
+	}
+	zr.Multistream(false)
+	data, err := io.ReadAll(io.LimitReader(zr, MAX_MEMBER_SIZE+1))
+	if err != nil {
+		return member{err: err}
+	}
+	if len(data) > MAX_MEMBER_SIZE {
+		return member{err: errMemberTooLarge}
+	}
+	// gzip reads the member from buffered, what is still buffered belongs to the next member
+	read, _ := section.Seek(0, io.SeekCurrent)
+	return member{data: data, end: offset + read - int64(buffered.Buffered())}
+}
+
+// findHeaders returns the offsets of the gzip headers in the first size bytes of r
+func findHeaders(r io.ReaderAt, size int64) ([]int64, error) {
+	var offsets []int64
+	buf := make([]byte, BUFFER_SIZE)
+	for start := int64(0); start < size; {
+		n, err := r.ReadAt(buf, start)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		chunk := buf[:n]
+		for i := 0; ; {
+			at := bytes.Index(chunk[i:], gzipHeader)
+			if at < 0 {
+				break
+			}
+			offsets = append(offsets, start+int64(i+at))
+			i += at + 1
+		}
+		if int64(n) < int64(len(buf)) {
+			break
+		}
+		// a header can span two chunks
+		start += int64(n - len(gzipHeader) + 1)
+	}
+	return offsets, nil
+}
@@ -1,26 +1,75 @@
 package pipelines
 
 import (
-	"fmt"
-	"os"
+	"context"
+	"io"
+	"time"
 
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/progress"
 	"github.com/brcgo/src/workers"
 )
 
-func IdeomotaticPipeline[T, U any](fname string, parser func(string) (T, error), collector func(T), printer func(), verbose bool) {
-	lines := make(chan string)
+// IdeomotaticPipeline returns the bytes of r read, when ctx is cancelled the lines already read are collected
+func IdeomotaticPipeline[T any](ctx context.Context, r io.Reader, parser func(string) (T, error), collector func(T), onError func(workers.Line, error), counters *progress.Counters) (int64, error) {
+	lines := make(chan workers.Line)
 	parsed := make(chan T)
 
+	type readResult struct {
+		read int64
+		err  error
+	}
+	done := make(chan readResult, 1)
 	go func() {
-		if err := workers.GetLines(fname, lines); err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
-		}
+		read, err := workers.GetLines(ctx, r, lines, counters)
+		done <- readResult{read, err}
 	}()
 
-	go workers.ParseLines[T](lines, parsed, parser)
+	go workers.ParseLines[T](lines, parsed, parser, onError)
 
 	workers.Collect(parsed, collector)
 
-	printer()
+	res := <-done
+	return res.read, res.err
+}
+
+// Reader, parser and collector stages connected by channels
+func IdiomaticPipeline(ctx context.Context, src Source, opts Options) (*domain.Result, error) {
+	startTime := time.Now()
+
+	input, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	hashmap := make(map[string]*domain.StationData)
+	collector := func(data domain.StringFloat) {
+		n := len(hashmap)
+		domain.Aggregate(data, &hashmap, opts.newStats)
+		opts.Progress.AddStations(int64(len(hashmap) - n))
+	}
+
+	errors := opts.errorLog()
+	reject := rejecter(errors)
+	onError := func(line workers.Line, err error) {
+		if err != domain.ErrFiltered {
+			reject(line, err)
+		}
+	}
+	parser := func(line string) (domain.StringFloat, error) {
+		data, err := domain.ParseStringFloat(line)
+		if err == nil && !opts.Filter.KeepFloat(data.Key, data.Value) {
+			return data, domain.ErrFiltered
+		}
+		return data, err
+	}
+
+	read, err := IdeomotaticPipeline(ctx, input, parser, collector, onError, opts.Progress)
 
+	resultMap := make(map[string]domain.StationData, len(hashmap))
+	for k, v := range hashmap {
+		resultMap[k] = *v
+	}
+	return partial(ctx, toResult(resultMap, errors, opts.Filter, read, startTime, time.Now()), err)
 }
@@ -0,0 +1,62 @@
+package pipelines
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/workers"
+	"github.com/jnsoft/jngo/pipeline"
+)
+
+// Pipeline built with jngo/pipeline, parallel parsers feeding parallel aggregators of a shared map
+func JngoPipeline(ctx context.Context, src Source, opts Options) (*domain.Result, error) {
+	startTime := time.Now()
+
+	input, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	hashmap := make(map[string]*domain.StationData)
+	var mu sync.Mutex
+	errors := opts.errorLog()
+	reject := rejecter(errors)
+	parser := func(line workers.Line) (domain.StringFloat, error) {
+		data, err := domain.ParseStringFloat(line.Text)
+		if err != nil {
+			reject(line, err)
+		} else if !opts.Filter.KeepFloat(data.Key, data.Value) {
+			err = domain.ErrFiltered
+		}
+		return data, err
+	}
+
+	// the source stops when ctx is cancelled, the following stages drain what was read
+	var read int64
+	var readErr error
+	pb := pipeline.FromSource(func(out chan<- workers.Line) error {
+		read, readErr = workers.GetLines(ctx, input, out, opts.Progress)
+		return readErr
+	})
+
+	pb2 := pipeline.Then(pb, pipeline.ParallelMapStage[workers.Line, domain.StringFloat](opts.ParserWorkers, parser))
+
+	pb3 := pipeline.Then(pb2, pipeline.ParallelDoStage[domain.StringFloat](opts.AggregatorWorkers, func(data domain.StringFloat) {
+		mu.Lock()
+		defer mu.Unlock()
+		n := len(hashmap)
+		domain.Aggregate(data, &hashmap, opts.newStats)
+		opts.Progress.AddStations(int64(len(hashmap) - n))
+	}))
+
+	pipeline.Run(pb3, func() {})
+
+	resultMap := make(map[string]domain.StationData, len(hashmap))
+	for k, v := range hashmap {
+		resultMap[k] = *v
+	}
+	return partial(ctx, toResult(resultMap, errors, opts.Filter, read, startTime, time.Now()), readErr)
+}
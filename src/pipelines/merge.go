@@ -0,0 +1,32 @@
+package pipelines
+
+import (
+	"sync"
+
+	"github.com/brcgo/src/domain"
+)
+
+// Above this many partial results merging is done pairwise in parallel
+const TREE_MERGE_THRESHOLD = 4
+
+// mergeResults combines partial results into the first one, as a tree reduction when there are many
+func mergeResults(results []*domain.ByteResult) *domain.ByteResult {
+	for len(results) > TREE_MERGE_THRESHOLD {
+		half := (len(results) + 1) / 2
+		var wg sync.WaitGroup
+		for i := half; i < len(results); i++ {
+			wg.Add(1)
+			go func(dst, src *domain.ByteResult) {
+				defer wg.Done()
+				dst.Merge(src)
+			}(results[i-half], results[i])
+		}
+		wg.Wait()
+		results = results[:half]
+	}
+
+	for _, r := range results[1:] {
+		results[0].Merge(r)
+	}
+	return results[0]
+}
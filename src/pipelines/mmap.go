@@ -0,0 +1,351 @@
+package pipelines
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brcgo/src/domain"
+)
+
+// Memory maps the file and parses opts.Workers newline aligned ranges of it in place.
+// Falls back to ReadAt based ranges when the file cannot be mapped and to streaming for pipes
+// and sources that are not files.
+func MmapPipeline(ctx context.Context, src Source, opts Options) (*domain.Result, error) {
+	input, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	return ProcessReader(ctx, input, opts)
+}
+
+// ProcessReader reads files with ProcessFile, readers with ReadAt and a known size in ranges
+// and streams everything else
+func ProcessReader(ctx context.Context, r io.Reader, opts Options) (*domain.Result, error) {
+	switch r := r.(type) {
+	case *os.File:
+		return ProcessFile(ctx, r, opts)
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return ProcessReaderAt(ctx, r, r.Size(), opts)
+	}
+	return ProcessBytes(ctx, r, opts)
+}
+
+// ProcessFile picks the fastest way to read file: mmap, ReadAt ranges or a stream
+func ProcessFile(ctx context.Context, file *os.File, opts Options) (*domain.Result, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return ProcessBytes(ctx, file, opts)
+	}
+
+	size := info.Size()
+	if size > 0 {
+		if data, unmap, err := mmapFile(file, size); err == nil {
+			defer unmap()
+			return ProcessMapped(ctx, data, opts)
+		}
+	}
+	return ProcessReaderAt(ctx, file, size, opts)
+}
+
+// ProcessMapped parses data in place without copying, the result does not reference data
+func ProcessMapped(ctx context.Context, data []byte, opts Options) (*domain.Result, error) {
+	opts = opts.withDefaults()
+	startTime := time.Now()
+
+	bounds := splitBytes(data, opts.Workers)
+	var lines []int64
+	if opts.quarantines() {
+		lines, _ = firstLines(len(bounds)-1, func(i int) (int64, error) {
+			return int64(bytes.Count(data[bounds[i]:bounds[i+1]], []byte{ASCII_NEWLINE})), nil
+		})
+	}
+	results, ends := parseRanges(len(bounds)-1, opts, func(i int, result *domain.ByteResult) int64 {
+		first := int64(1)
+		if lines != nil {
+			first = lines[i]
+		}
+		end := parseMapped(ctx, data[bounds[i]:bounds[i+1]], int64(bounds[i]), first, opts, result)
+		// line numbers of rejected lines are relative to the range until the lines before it are counted
+		if lines == nil && result.Errors().Count() > 0 {
+			result.Errors().ShiftLines(int64(bytes.Count(data[:bounds[i]], []byte{ASCII_NEWLINE})))
+		}
+		return end
+	})
+	starts := make([]int64, len(bounds))
+	for i, b := range bounds {
+		starts[i] = int64(b)
+	}
+	return finishRanges(ctx, results, starts, ends, nil, startTime)
+}
+
+// ProcessReaderAt reads opts.Workers newline aligned ranges of r concurrently
+func ProcessReaderAt(ctx context.Context, r io.ReaderAt, size int64, opts Options) (*domain.Result, error) {
+	opts = opts.withDefaults()
+	startTime := time.Now()
+
+	bounds, err := splitReaderAt(r, size, opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+	var lines []int64
+	if opts.quarantines() {
+		lines, err = firstLines(len(bounds)-1, func(i int) (int64, error) {
+			return countLines(io.NewSectionReader(r, bounds[i], bounds[i+1]-bounds[i]), opts.BufferSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var errOnce sync.Once
+	var readErr error
+	results, ends := parseRanges(len(bounds)-1, opts, func(i int, result *domain.ByteResult) int64 {
+		section := io.NewSectionReader(r, bounds[i], bounds[i+1]-bounds[i])
+		first := int64(1)
+		if lines != nil {
+			first = lines[i]
+		}
+		parsed, err := parseStream(ctx, section, bounds[i], first, opts, result)
+		if lines == nil && result.Errors().Count() > 0 {
+			before, cerr := countLines(io.NewSectionReader(r, 0, bounds[i]), opts.BufferSize)
+			result.Errors().ShiftLines(before)
+			err = errors.Join(err, cerr)
+		}
+		if err != nil {
+			errOnce.Do(func() { readErr = err })
+		}
+		return bounds[i] + parsed
+	})
+	return finishRanges(ctx, results, bounds, ends, readErr, startTime)
+}
+
+// firstLines returns the line numbers n ranges start at, from the newlines of every range counted concurrently.
+// They are needed before parsing when rejected lines are quarantined as they are rejected.
+func firstLines(n int, count func(i int) (int64, error)) ([]int64, error) {
+	counts := make([]int64, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range counts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			counts[i], errs[i] = count(i)
+		}(i)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	lines := make([]int64, n)
+	line := int64(1)
+	for i, c := range counts {
+		lines[i] = line
+		line += c
+	}
+	return lines, nil
+}
+
+// parseRanges runs parse for every range, each with its own result, and returns the offsets the ranges were parsed up to
+func parseRanges(n int, opts Options, parse func(i int, result *domain.ByteResult) int64) ([]*domain.ByteResult, []int64) {
+	results := make([]*domain.ByteResult, n)
+	ends := make([]int64, n)
+	var wg sync.WaitGroup
+	for i := range results {
+		results[i] = opts.byteResult()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ends[i] = parse(i, results[i])
+		}(i)
+	}
+	wg.Wait()
+	return results, ends
+}
+
+// finishRanges merges the results of the ranges starting at bounds, a range that did not reach
+// the next bound was stopped by ctx and makes the result partial
+func finishRanges(ctx context.Context, results []*domain.ByteResult, bounds, ends []int64, err error, startTime time.Time) (*domain.Result, error) {
+	if err != nil && (ctx.Err() == nil || !errors.Is(err, ctx.Err())) {
+		return nil, err
+	}
+	processed := time.Now()
+
+	res := mergeResults(results).ToResult()
+	offset := bounds[len(bounds)-1] // all input before it is aggregated
+	for i, end := range ends {
+		res.Bytes += end - bounds[i]
+		if end < bounds[i+1] {
+			offset = min(offset, end)
+		}
+	}
+	if offset < bounds[len(bounds)-1] && err == nil {
+		err = ctx.Err()
+	}
+	res.Timings.Started = startTime
+	res.Timings.Process = processed.Sub(startTime)
+	res.Timings.Merge = time.Since(processed)
+	res.Timings.Total = time.Since(startTime)
+
+	res, err = partial(ctx, res, err)
+	if res != nil && res.Partial {
+		res.Offset = offset
+	}
+	return res, err
+}
+
+// splitBytes returns n+1 boundaries of n ranges of data, each range starting at the beginning of a line
+func splitBytes(data []byte, n int) []int {
+	size := len(data)
+	bounds := make([]int, n+1)
+	bounds[n] = size
+	for i := 1; i < n; i++ {
+		pos := max(i*size/n, bounds[i-1])
+		if pos > 0 && pos < size {
+			if nl := bytes.IndexByte(data[pos-1:], ASCII_NEWLINE); nl >= 0 {
+				pos += nl
+			} else {
+				pos = size
+			}
+		}
+		bounds[i] = pos
+	}
+	return bounds
+}
+
+// splitReaderAt is splitBytes for data that is read with ReadAt
+func splitReaderAt(r io.ReaderAt, size int64, n int) ([]int64, error) {
+	bounds := make([]int64, n+1)
+	bounds[n] = size
+	window := make([]byte, 256)
+	for i := 1; i < n; i++ {
+		pos := max(int64(i)*size/int64(n), bounds[i-1])
+		if pos > 0 && pos < size {
+			next, err := nextLineStart(r, pos-1, size, window)
+			if err != nil {
+				return nil, err
+			}
+			pos = next
+		}
+		bounds[i] = pos
+	}
+	return bounds, nil
+}
+
+// nextLineStart returns the offset following the first newline at or after pos, size when there is none
+func nextLineStart(r io.ReaderAt, pos, size int64, window []byte) (int64, error) {
+	for pos < size {
+		n, err := r.ReadAt(window, pos)
+		if nl := bytes.IndexByte(window[:n], ASCII_NEWLINE); nl >= 0 {
+			return pos + int64(nl) + 1, nil
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if n == 0 {
+			break
+		}
+		pos += int64(n)
+	}
+	return size, nil
+}
+
+// countLines counts the newlines of r
+func countLines(r io.Reader, bufferSize int) (int64, error) {
+	var lines int64
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := r.Read(buf)
+		lines += int64(bytes.Count(buf[:n], []byte{ASCII_NEWLINE}))
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return lines, err
+		}
+	}
+}
+
+// parseStream aggregates r read sequentially in chunks of opts.BufferSize into a result owned by the caller.
+// offset and line are the position of r in the input, the line number of its first line.
+// Returns the number of bytes parsed, less than read when ctx is cancelled.
+func parseStream(ctx context.Context, r io.Reader, offset, line int64, opts Options, result *domain.ByteResult) (int64, error) {
+	bufferSize := opts.BufferSize
+	var total int64
+	buf := make([]byte, bufferSize)
+	leftover := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return total - int64(leftover), err
+		}
+		if leftover == len(buf) {
+			buf = append(buf, make([]byte, bufferSize)...) // line longer than the buffer
+		}
+		n, err := io.ReadFull(r, buf[leftover:])
+		total += int64(n)
+		data := buf[:leftover+n]
+		start := offset + total - int64(len(data))
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			parseRangeAt(data, start, line, opts, result)
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+
+		lastNewline := bytes.LastIndexByte(data, ASCII_NEWLINE)
+		if lastNewline == -1 {
+			leftover = len(data)
+			continue
+		}
+		lines, parsed := ParseBuffer(data[:lastNewline+1], start, line, result)
+		line += lines
+		opts.Progress.Add(int64(lastNewline+1), parsed)
+		opts.Progress.SeenStations(int64(result.NoOfStations()))
+		// station names are interned, so the buffer is reused for the next chunk
+		leftover = copy(buf, data[lastNewline+1:])
+	}
+}
+
+// parseMapped aggregates all lines of buf starting at offset and line number line in chunks of about opts.BufferSize.
+// Returns the offset reached, before the end of buf when ctx is cancelled.
+func parseMapped(ctx context.Context, buf []byte, offset, line int64, opts Options, result *domain.ByteResult) int64 {
+	bufferSize := opts.BufferSize
+	for len(buf) > bufferSize {
+		if ctx.Err() != nil {
+			return offset
+		}
+		nl := bytes.IndexByte(buf[bufferSize:], ASCII_NEWLINE)
+		if nl == -1 {
+			break
+		}
+		end := bufferSize + nl + 1
+		lines, parsed := ParseBuffer(buf[:end], offset, line, result)
+		line += lines
+		opts.Progress.Add(int64(end), parsed)
+		opts.Progress.SeenStations(int64(result.NoOfStations()))
+		buf, offset = buf[end:], offset+int64(end)
+	}
+	parseRangeAt(buf, offset, line, opts, result)
+	return offset + int64(len(buf))
+}
+
+// parseRangeAt aggregates all lines of buf, including a last line without newline
+func parseRangeAt(buf []byte, offset, line int64, opts Options, result *domain.ByteResult) {
+	lines, parsed := ParseBuffer(buf, offset, line, result)
+	lastNewline := bytes.LastIndexByte(buf, ASCII_NEWLINE)
+	parsed += parseLine(buf[lastNewline+1:], offset+int64(lastNewline+1), line+lines, result)
+	opts.Progress.Add(int64(len(buf)), parsed)
+	opts.Progress.SeenStations(int64(result.NoOfStations()))
+}
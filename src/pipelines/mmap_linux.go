@@ -0,0 +1,15 @@
+package pipelines
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile maps size bytes of file read only, the returned function unmaps it
+func mmapFile(file *os.File, size int64) ([]byte, func() error, error) {
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return syscall.Munmap(data) }, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package pipelines
+
+import (
+	"errors"
+	"os"
+)
+
+func mmapFile(file *os.File, size int64) ([]byte, func() error, error) {
+	return nil, nil, errors.New("mmap is only supported on linux")
+}
@@ -1,67 +1,114 @@
 package pipelines
 
 import (
-	"bufio"
-	"fmt"
+	"context"
 	"math"
-	"os"
-	"sort"
 	"time"
 
 	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/workers"
 )
 
-func Naive(fname string) error {
+func Naive(ctx context.Context, src Source, opts Options) (*domain.Result, error) {
 	startTime := time.Now()
 
-	file, err := os.Open(fname)
+	input, err := src.Open()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer file.Close()
+	defer input.Close()
 
 	resultMap := make(map[string]domain.StationData)
-	cnt := 0
+	var cnt, lineNo int64
+	errors := opts.errorLog()
 
-	scanner := bufio.NewScanner(file)
+	var stopped error
+	tracked := tracker{counters: opts.Progress}
+	scanner := workers.NewLineScanner(input)
 	for scanner.Scan() {
+		lineNo++
+		if lineNo%CTX_CHECK_LINES == 0 {
+			tracked.update(scanner.Offset, cnt)
+			opts.Progress.SeenStations(int64(len(resultMap)))
+			if ctx.Err() != nil {
+				stopped = ctx.Err()
+				break
+			}
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
 		cnt++
-		data, _ := domain.ParseStringFloat(scanner.Text())
+		data, err := domain.ParseStringFloat(scanner.Text())
+		if err != nil {
+			rejectLine(errors, err, scanner.Text(), lineNo, scanner.Offset)
+			continue
+		}
+		if !opts.Filter.KeepFloat(data.Key, data.Value) {
+			continue
+		}
 		aggregated, exists := resultMap[data.Key]
 		if !exists {
-			resultMap[data.Key] = domain.StationData{
+			aggregated = domain.StationData{
 				Min:   data.Value,
 				Max:   data.Value,
 				Sum:   data.Value,
 				Count: 1,
+				Stats: opts.newStats(),
 			}
 		} else {
-			resultMap[data.Key] = domain.StationData{
+			aggregated = domain.StationData{
 				Min:   math.Min(data.Value, aggregated.Min),
 				Max:   math.Max(data.Value, aggregated.Max),
 				Sum:   data.Value + aggregated.Sum,
 				Count: aggregated.Count + 1,
+				Stats: aggregated.Stats,
 			}
 		}
+		if aggregated.Stats != nil {
+			aggregated.Stats.AddFloat(data.Value)
+		}
+		resultMap[data.Key] = aggregated
 
 	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if stopped == nil {
+		tracked.update(scanner.Next, cnt)
+	}
 
-	keys := make([]string, 0, len(resultMap))
-	for k := range resultMap {
-		keys = append(keys, k)
+	result := domain.NewResult()
+	for k, v := range resultMap {
+		data := v.ToInt()
+		result.Stations[k] = &data
 	}
-	sort.Strings(keys)
+	result.Lines = cnt
+	result.Bytes = scanner.Next
+	result.Errors = errors.Count()
+	result.Filtered = opts.Filter.Filtered()
+	result.ParseErrors = errors.Errors()
+	result.Timings.Started = startTime
+	result.Timings.Process = time.Since(startTime)
+	result.Timings.Total = result.Timings.Process
 
-	//if verbose {
-	//	fmt.Println("\n Final aggregated results:")
-	//	for _, k := range keys {
-	//		fmt.Printf("%s=%s\n", k, resultMap[k].String())
-	//	}
-	//}
+	if stopped != nil {
+		result.Bytes = scanner.Offset
+		return partial(ctx, result, stopped)
+	}
+	return result, nil
+}
 
-	elapsed := time.Since(startTime)
-	fmt.Printf("\nDone in %s. Processed %d lines, %d unique keys\n",
-		elapsed, cnt, len(resultMap))
+// rejecter reports the lines read by workers.GetLines that fail to parse with rejectLine
+func rejecter(errors *domain.ErrorLog) func(workers.Line, error) {
+	return func(line workers.Line, err error) {
+		rejectLine(errors, err, line.Text, line.Line, line.Offset)
+	}
+}
 
-	return scanner.Err()
+// rejectLine logs a line that failed to parse at its position in the input
+func rejectLine(errors *domain.ErrorLog, err error, line string, lineNo, offset int64) {
+	perr := domain.AsParseError(err, line)
+	perr.Line, perr.Offset = lineNo, offset
+	errors.Add(perr)
 }
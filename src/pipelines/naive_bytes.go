@@ -1,8 +1,9 @@
 package pipelines
 
 import (
-	"fmt"
-	"os"
+	"bytes"
+	"context"
+	"io"
 	"sync"
 	"time"
 
@@ -12,27 +13,61 @@ import (
 const BUFFER_SIZE = 1024 * 1024
 const ASCII_NEWLINE = '\n'
 
-func NaiveBytes(fname string, MAX_CONCURRENT int) (string, error) {
+// Reads the file in chunks of opts.BufferSize and parses up to opts.Workers chunks concurrently
+func NaiveBytes(ctx context.Context, src Source, opts Options) (*domain.Result, error) {
+	input, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	return ProcessBytes(ctx, input, opts)
+}
 
+// ProcessBytes is the engine of NaiveBytes, reading any stream in chunks of opts.BufferSize.
+// Each of the opts.Workers workers aggregates into its own station table, merged at the end.
+func ProcessBytes(ctx context.Context, r io.Reader, opts Options) (*domain.Result, error) {
+	opts = opts.withDefaults()
 	startTime := time.Now()
 
-	file, err := os.Open(fname)
-	if err != nil {
-		return "", err
+	// station names are interned, so chunk buffers can be reused once parsed
+	pool := sync.Pool{New: func() any { return make([]byte, 0, opts.BufferSize) }}
+
+	chunks := make(chan chunk, opts.Workers)
+	results := make([]*domain.ByteResult, opts.Workers)
+	var wg sync.WaitGroup
+	for i := range results {
+		results[i] = opts.byteResult()
+		wg.Add(1)
+		go func(result *domain.ByteResult) {
+			defer wg.Done()
+			for c := range chunks {
+				_, parsed := ParseBuffer(c.buf, c.offset, c.line, result)
+				opts.Progress.Add(int64(len(c.buf)), parsed)
+				opts.Progress.SeenStations(int64(result.NoOfStations()))
+				pool.Put(c.buf[:0])
+			}
+		}(results[i])
 	}
-	defer file.Close()
 
-	result := domain.NewByteResult()
-	buffer := make([]byte, BUFFER_SIZE)
+	buffer := make([]byte, opts.BufferSize)
 	var leftover []byte
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, MAX_CONCURRENT)
+	var totalRead int64
+	var readErr error
+	var offset int64 // offset of leftover in the input
+	line := int64(1) // line number of leftover
 
 	for {
-		bytesRead, err := file.Read(buffer)
+		if err := ctx.Err(); err != nil {
+			readErr = err
+			break
+		}
+		bytesRead, err := r.Read(buffer)
 		if bytesRead == 0 && err != nil {
+			readErr = err
 			break
 		}
+		totalRead += int64(bytesRead)
 
 		// combine leftover with current buffer
 		combined := append(leftover, buffer[:bytesRead]...)
@@ -49,6 +84,7 @@ func NaiveBytes(fname string, MAX_CONCURRENT int) (string, error) {
 		if lastNewline == -1 {
 			leftover = combined
 			if err != nil {
+				readErr = err
 				break
 			}
 			continue
@@ -59,51 +95,78 @@ func NaiveBytes(fname string, MAX_CONCURRENT int) (string, error) {
 			leftover = combined[lastNewline+1:]
 		}
 
-		parseBuffer := make([]byte, lastNewline+1)
-		copy(parseBuffer, combined[:lastNewline+1])
-
-		wg.Add(1)
-		sem <- struct{}{} // Acquire a semaphore slot
-		go func(buf []byte) {
-			defer wg.Done()
-			defer func() { <-sem }() // Release the semaphore slot
-			ParseBuffer(buf, result)
-		}(parseBuffer)
+		parseBuffer := append(pool.Get().([]byte), combined[:lastNewline+1]...)
+		chunks <- chunk{buf: parseBuffer, offset: offset, line: line}
+		offset += int64(len(parseBuffer))
+		line += int64(bytes.Count(parseBuffer, []byte{ASCII_NEWLINE}))
 
 		if err != nil {
+			readErr = err
 			break
 		}
 	}
-	if len(leftover) > 0 {
-		reading := domain.NewByteStationReadingFromBytes(leftover)
-		result.Add(reading)
-	}
 
+	// when ctx is cancelled the chunks already read are still parsed
+	close(chunks)
 	wg.Wait()
-	res_str := result.GetSortedResults()
+	if readErr == io.EOF {
+		readErr = nil
+	}
+	processed := time.Now()
+
+	result := mergeResults(results)
+	if readErr == nil {
+		opts.Progress.Add(int64(len(leftover)), parseLine(leftover, offset, line, result))
+		offset = totalRead
+	}
+
+	res := result.ToResult()
+	res.Bytes = offset
+	res.Timings.Started = startTime
+	res.Timings.Process = processed.Sub(startTime)
+	res.Timings.Merge = time.Since(processed)
+	res.Timings.Total = time.Since(startTime)
 
-	elapsed := time.Since(startTime)
-	fmt.Printf("\nDone in %s. Processed %d lines, %d unique keys\n",
-		elapsed, result.NoOfInputs(), result.NoOfStations())
+	return partial(ctx, res, readErr)
+}
 
-	return res_str, nil
+// chunk of complete lines starting at offset and line number line of the input
+type chunk struct {
+	buf    []byte
+	offset int64
+	line   int64
 }
 
-func ParseBuffer(parseBuffer []byte, result *domain.ByteResult) {
+// ParseBuffer aggregates the complete lines of parseBuffer, result must be owned by the calling goroutine.
+// offset and line are the position of parseBuffer in the input, used to report rejected lines.
+// Returns the number of lines and of non-empty lines parsed.
+func ParseBuffer(parseBuffer []byte, offset, line int64, result *domain.ByteResult) (lines, parsed int64) {
 	lineStartIdx := 0
 	for i := 0; i < len(parseBuffer); i++ {
 		if parseBuffer[i] == ASCII_NEWLINE {
-			lineEndIdx := i
-			// Handle \r\n (Windows line endings)
-			if lineEndIdx > lineStartIdx && parseBuffer[lineEndIdx-1] == '\r' {
-				lineEndIdx--
-			}
-			line := parseBuffer[lineStartIdx:lineEndIdx]
-			if len(line) > 0 {
-				reading := domain.NewByteStationReadingFromBytes(line)
-				result.Add(reading)
-			}
+			parsed += parseLine(parseBuffer[lineStartIdx:i], offset+int64(lineStartIdx), line+lines, result)
+			lines++
 			lineStartIdx = i + 1
 		}
 	}
+	return lines, parsed
+}
+
+// parseLine aggregates a single line without its newline, empty lines are skipped.
+// Returns the number of lines parsed, 0 for an empty line.
+func parseLine(line []byte, offset, lineNo int64, result *domain.ByteResult) int64 {
+	// Handle \r\n (Windows line endings)
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	if len(line) == 0 {
+		return 0
+	}
+	reading, err := domain.NewByteStationReadingFromBytes(line)
+	if err != nil {
+		rejectLine(result.Errors(), err, string(line), lineNo, offset)
+		return 1
+	}
+	if result.Keep(reading) {
+		result.AddLocal(reading)
+	}
+	return 1
 }
@@ -0,0 +1,99 @@
+package pipelines
+
+import (
+	"context"
+	"time"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/workers"
+	"github.com/jnsoft/jngo/misc"
+)
+
+// Scans the file line by line and aggregates integer tenths in a map of values
+func NaiveInt(ctx context.Context, src Source, opts Options) (*domain.Result, error) {
+
+	startTime := time.Now()
+	input, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	resultMap := make(map[string]domain.StationDataInt)
+	var cnt, lineNo int64
+	errors := opts.errorLog()
+
+	var stopped error
+	tracked := tracker{counters: opts.Progress}
+	scanner := workers.NewLineScanner(input)
+	for scanner.Scan() {
+		lineNo++
+		if lineNo%CTX_CHECK_LINES == 0 {
+			tracked.update(scanner.Offset, cnt)
+			opts.Progress.SeenStations(int64(len(resultMap)))
+			if ctx.Err() != nil {
+				stopped = ctx.Err()
+				break
+			}
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		cnt++
+		data, err := domain.ParseStringInt(scanner.Text())
+		if err != nil {
+			rejectLine(errors, err, scanner.Text(), lineNo, scanner.Offset)
+			continue
+		}
+		if !opts.Filter.Keep(data.Key, data.Value) {
+			continue
+		}
+		aggregated, exists := resultMap[data.Key]
+		if !exists {
+			aggregated = domain.StationDataInt{
+				Min:   data.Value,
+				Max:   data.Value,
+				Sum:   data.Value,
+				Count: 1,
+				Stats: opts.newStats(),
+			}
+		} else {
+			aggregated = domain.StationDataInt{
+				Min:   misc.Min(data.Value, aggregated.Min),
+				Max:   misc.Max(data.Value, aggregated.Max),
+				Sum:   data.Value + aggregated.Sum,
+				Count: aggregated.Count + 1,
+				Stats: aggregated.Stats,
+			}
+		}
+		if aggregated.Stats != nil {
+			aggregated.Stats.Add(data.Value)
+		}
+		resultMap[data.Key] = aggregated
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if stopped == nil {
+		tracked.update(scanner.Next, cnt)
+	}
+
+	result := domain.NewResult()
+	for k, v := range resultMap {
+		result.Stations[k] = &v
+	}
+	result.Lines = cnt
+	result.Bytes = scanner.Next
+	result.Errors = errors.Count()
+	result.Filtered = opts.Filter.Filtered()
+	result.ParseErrors = errors.Errors()
+	result.Timings.Started = startTime
+	result.Timings.Process = time.Since(startTime)
+	result.Timings.Total = result.Timings.Process
+
+	if stopped != nil {
+		result.Bytes = scanner.Offset
+		return partial(ctx, result, stopped)
+	}
+	return result, nil
+}
@@ -0,0 +1,237 @@
+package pipelines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/progress"
+	"github.com/brcgo/src/workers"
+)
+
+const (
+	NO_OF_PARSER_WORKERS     = 4
+	NO_OF_AGGREGATOR_WORKERS = 4
+	CTX_CHECK_LINES          = workers.CTX_CHECK_LINES
+)
+
+// Options are the tuning knobs of a pipeline, each pipeline uses the ones it needs
+type Options struct {
+	Workers           int             // concurrent workers (bytes, mmap, workerpool)
+	ParserWorkers     int             // (rpa, jngo)
+	AggregatorWorkers int             // (rpa, jngo)
+	BufferSize        int             // read buffer size in bytes (bytes, mmap fallback)
+	Hash              domain.HashFunc // station table hash function (bytes, mmap)
+	Stats             bool            // extended statistics per station, see domain.Stats
+	Filter            *domain.Filter  // lines to aggregate, nil for all
+
+	OnReject      domain.OnReject // what to do with lines that fail to parse
+	Quarantine    io.Writer       // receives the rejected lines with REJECT_QUARANTINE, may be nil
+	MaxRejectRate float64         // fail the run when more than this fraction of the lines is rejected, 0 disables
+
+	Progress *progress.Counters // receives the progress of the run, may be nil
+
+	policy     *domain.RejectPolicy // set by Run
+	budget     *domain.RejectBudget // shared by the runs of RunFiles
+	quarantine *domain.Quarantine   // shared by the runs of RunFiles
+	file       string               // name of the source run by RunFiles
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Workers:           runtime.NumCPU(),
+		ParserWorkers:     NO_OF_PARSER_WORKERS,
+		AggregatorWorkers: NO_OF_AGGREGATOR_WORKERS,
+		BufferSize:        BUFFER_SIZE,
+	}
+}
+
+// withDefaults replaces unset options with the defaults
+func (o Options) withDefaults() Options {
+	def := DefaultOptions()
+	if o.Workers <= 0 {
+		o.Workers = def.Workers
+	}
+	if o.ParserWorkers <= 0 {
+		o.ParserWorkers = def.ParserWorkers
+	}
+	if o.AggregatorWorkers <= 0 {
+		o.AggregatorWorkers = def.AggregatorWorkers
+	}
+	if o.BufferSize <= 0 {
+		o.BufferSize = def.BufferSize
+	}
+	return o
+}
+
+// tracker reports the growth of the running totals of a sequential pipeline to a progress.Counters
+type tracker struct {
+	counters     *progress.Counters
+	bytes, lines int64
+}
+
+func (t *tracker) update(bytes, lines int64) {
+	t.counters.Add(bytes-t.bytes, lines-t.lines)
+	t.bytes, t.lines = bytes, lines
+}
+
+// errorLog for the rejected lines of a run
+func (o Options) errorLog() *domain.ErrorLog {
+	return domain.NewErrorLog(o.policy)
+}
+
+// byteResult for the lines aggregated by one worker
+func (o Options) byteResult() *domain.ByteResult {
+	result := domain.NewByteResultWithHash(o.Hash)
+	result.Errors().SetPolicy(o.policy)
+	if o.Stats {
+		result.EnableStats()
+	}
+	result.SetFilter(o.Filter)
+	return result
+}
+
+// newStats for a new station, nil unless extended statistics are enabled
+func (o Options) newStats() *domain.Stats {
+	if !o.Stats {
+		return nil
+	}
+	return domain.NewStats()
+}
+
+// Pipeline aggregates a source. When ctx is cancelled Run returns the partial result
+// of the input read so far together with the error of ctx.
+type Pipeline interface {
+	Run(ctx context.Context, src Source, opts Options) (*domain.Result, error)
+}
+
+// partial marks res as partial when err is the cancellation of ctx, other errors fail the run
+func partial(ctx context.Context, res *domain.Result, err error) (*domain.Result, error) {
+	if err == nil {
+		return res, nil
+	}
+	if ctx.Err() == nil || !errors.Is(err, ctx.Err()) {
+		return nil, err
+	}
+	res.Partial = true
+	res.Offset = res.Bytes
+	return res, err
+}
+
+// PipelineFunc adapts a function to the Pipeline interface
+type PipelineFunc func(ctx context.Context, src Source, opts Options) (*domain.Result, error)
+
+// Run applies the reject policy of opts, a failing line cancels the context of f.
+// The lines filtered out are counted per run.
+func (f PipelineFunc) Run(ctx context.Context, src Source, opts Options) (*domain.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	opts = opts.forRun(cancel)
+	res, err := f(ctx, src, opts)
+	if err := finishRun(res, opts); err != nil {
+		return nil, err
+	}
+	return res, err
+}
+
+// forRun prepares opts for a run, a failing line or an exceeded reject budget calls abort
+func (o Options) forRun(abort context.CancelCauseFunc) Options {
+	o = o.withDefaults().withRejects()
+	o.Filter = o.Filter.ForRun()
+	o.policy = &domain.RejectPolicy{
+		OnReject:   o.OnReject,
+		Budget:     o.budget,
+		Quarantine: o.quarantine,
+		File:       o.file,
+		Abort:      abort,
+	}
+	return o
+}
+
+// withRejects sets up the reject budget and the quarantine writer, unless RunFiles shares its own
+func (o Options) withRejects() Options {
+	if o.budget == nil && o.MaxRejectRate > 0 {
+		if o.Progress == nil {
+			o.Progress = &progress.Counters{} // counts the lines seen by the budget
+		}
+		o.budget = domain.NewRejectBudget(o.MaxRejectRate, o.Progress.Lines)
+	}
+	if o.quarantine == nil && o.Quarantine != nil && o.OnReject == domain.REJECT_QUARANTINE {
+		o.quarantine = domain.NewQuarantine(o.Quarantine)
+	}
+	return o
+}
+
+// quarantines reports whether rejected lines are written as they are rejected, with their final line number
+func (o Options) quarantines() bool {
+	return o.quarantine != nil
+}
+
+// finishRun returns the error failing a run with the result res, which may be nil.
+// The quarantine is flushed also when the run failed or was cancelled.
+func finishRun(res *domain.Result, opts Options) error {
+	if opts.quarantine != nil {
+		if err := opts.quarantine.Flush(); err != nil {
+			return err
+		}
+	}
+	if err := opts.policy.Failed(); err != nil {
+		return err
+	}
+	if res == nil || opts.file != "" {
+		return nil // the runs of RunFiles are checked together on the merged result
+	}
+	return domain.CheckRejectRate(res, opts.MaxRejectRate)
+}
+
+const (
+	MODE_NAIVE      = "naive"
+	MODE_BYTES      = "bytes"
+	MODE_WORKERPOOL = "workerpool"
+	MODE_RPA        = "rpa"
+	MODE_IDIOMATIC  = "idiomatic"
+	MODE_JNGO       = "jngo"
+	MODE_INT        = "int"
+	MODE_MMAP       = "mmap"
+)
+
+var (
+	names    []string
+	registry = make(map[string]Pipeline)
+)
+
+func init() {
+	Register(MODE_NAIVE, PipelineFunc(Naive))
+	Register(MODE_BYTES, PipelineFunc(NaiveBytes))
+	Register(MODE_WORKERPOOL, PipelineFunc(WorkerpoolPipeline))
+	Register(MODE_RPA, PipelineFunc(ReadParseAggregatePipeline))
+	Register(MODE_IDIOMATIC, PipelineFunc(IdiomaticPipeline))
+	Register(MODE_JNGO, PipelineFunc(JngoPipeline))
+	Register(MODE_INT, PipelineFunc(NaiveInt))
+	Register(MODE_MMAP, PipelineFunc(MmapPipeline))
+}
+
+func Register(name string, p Pipeline) {
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("pipeline %q already registered", name))
+	}
+	names = append(names, name)
+	registry[name] = p
+}
+
+func Get(name string) (Pipeline, bool) {
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Names of the registered pipelines in registration order
+func Names() []string {
+	return append([]string(nil), names...)
+}
@@ -0,0 +1,377 @@
+package pipelines
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/progress"
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+const malformed = `Hamburg;12.0
+Hamburg
+Bulawayo;8.9
+
+Bulawayo;12.34
+Palembang;38.8
+`
+
+func TestRejectPolicy(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(fname, []byte(malformed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := FileSource(fname)
+
+	for _, mode := range Names() {
+		p, _ := Get(mode)
+
+		t.Run(mode+" skips malformed lines", func(t *testing.T) {
+			res, err := p.Run(context.Background(), src, Options{Workers: 3, BufferSize: 16})
+			AssertTrue(t, err == nil)
+			AssertEqual(t, res.Lines, int64(5))
+			AssertEqual(t, res.Errors, int64(2))
+			AssertEqual(t, res.NoOfStations(), 3)
+		})
+
+		t.Run(mode+" fails on the first malformed line", func(t *testing.T) {
+			_, err := p.Run(context.Background(), src, Options{Workers: 3, BufferSize: 16, OnReject: domain.REJECT_FAIL})
+			var perr *domain.ParseError
+			AssertTrue(t, errors.As(err, &perr))
+		})
+
+		t.Run(mode+" quarantines malformed lines", func(t *testing.T) {
+			var quarantine bytes.Buffer
+			opts := Options{Workers: 3, BufferSize: 16, OnReject: domain.REJECT_QUARANTINE, Quarantine: &quarantine}
+			res, err := p.Run(context.Background(), src, opts)
+			AssertTrue(t, err == nil)
+			AssertEqual(t, res.Errors, int64(2))
+			AssertEqual(t, strings.Count(quarantine.String(), "\n"), 2)
+			AssertTrue(t, strings.Contains(quarantine.String(), "Bulawayo;12.34"))
+			AssertTrue(t, strings.Contains(quarantine.String(), "2\t13\t"))
+			AssertTrue(t, strings.Contains(quarantine.String(), "5\t35\t"))
+		})
+
+		t.Run(mode+" fails when the reject budget is exceeded", func(t *testing.T) {
+			_, err := p.Run(context.Background(), src, Options{Workers: 3, BufferSize: 16, MaxRejectRate: 0.3})
+			AssertTrue(t, errors.Is(err, domain.ErrRejectBudget))
+			_, err = p.Run(context.Background(), src, Options{Workers: 3, BufferSize: 16, MaxRejectRate: 0.5})
+			AssertTrue(t, err == nil)
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.txt"), filepath.Join(dir, "second.txt")
+	if err := os.WriteFile(first, []byte("Hamburg;12.0\nBulawayo;8.9"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("Hamburg;-2.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const joined = "Hamburg;12.0\nBulawayo;8.9\nHamburg;-2.0\n"
+	want := "{Bulawayo=8.9/8.9/8.9, Hamburg=-2.0/5.0/12.0}"
+
+	sources := map[string]func() Source{
+		"string": func() Source { return StringSource("joined", joined) },
+		"reader": func() Source { return ReaderSource("joined", strings.NewReader(joined)) },
+		"multi":  func() Source { return MultiSource(FileSource(first), FileSource(second)) },
+	}
+	for name, source := range sources {
+		for _, mode := range Names() {
+			p, _ := Get(mode)
+			t.Run(name+" "+mode, func(t *testing.T) {
+				res, err := p.Run(context.Background(), source(), Options{Workers: 3, BufferSize: 16})
+				AssertTrue(t, err == nil)
+				AssertEqual(t, res.String(), want)
+				AssertEqual(t, res.Bytes, int64(len(joined)))
+			})
+		}
+	}
+
+	t.Run("multi size", func(t *testing.T) {
+		size, err := MultiSource(FileSource(first), FileSource(second)).Size()
+		AssertTrue(t, err == nil)
+		AssertEqual(t, size, int64(len(joined)-1))
+	})
+
+	t.Run("reader without seek is read once", func(t *testing.T) {
+		src := ReaderSource("once", bytes.NewBufferString(joined))
+		size, _ := src.Size()
+		AssertEqual(t, size, int64(UNKNOWN_SIZE))
+		_, err := Naive(context.Background(), src, Options{})
+		AssertTrue(t, err == nil)
+		_, err = Naive(context.Background(), src, Options{})
+		AssertTrue(t, errors.Is(err, ErrSourceConsumed))
+	})
+}
+
+func TestDecompressed(t *testing.T) {
+	const joined = "Hamburg;12.0\nBulawayo;8.9\nHamburg;-2.0\n"
+	want := "{Bulawayo=8.9/8.9/8.9, Hamburg=-2.0/5.0/12.0}"
+
+	var gz, members, zz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(joined))
+	gw.Close()
+	for _, line := range strings.SplitAfter(joined, "\n") {
+		gw := gzip.NewWriter(&members)
+		gw.Write([]byte(line))
+		gw.Close()
+	}
+	zw := zlib.NewWriter(&zz)
+	zw.Write([]byte(joined))
+	zw.Close()
+	bz, err := os.ReadFile(filepath.Join("testdata", "measurements.txt.bz2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inputs := map[string][]byte{
+		COMPRESSION_NONE:  []byte(joined),
+		COMPRESSION_GZIP:  gz.Bytes(),
+		"gzip members":    members.Bytes(),
+		COMPRESSION_ZLIB:  zz.Bytes(),
+		COMPRESSION_BZIP2: bz,
+	}
+	for name, data := range inputs {
+		for _, mode := range Names() {
+			p, _ := Get(mode)
+			t.Run(name+" "+mode, func(t *testing.T) {
+				res, err := p.Run(context.Background(), Decompressed(BytesSource(name, data), 3), Options{Workers: 3, BufferSize: 16})
+				AssertTrue(t, err == nil)
+				AssertEqual(t, res.String(), want)
+				AssertEqual(t, res.Bytes, int64(len(joined)))
+			})
+		}
+	}
+
+	t.Run("detects compression by magic bytes", func(t *testing.T) {
+		AssertEqual(t, DetectCompression(gz.Bytes()), COMPRESSION_GZIP)
+		AssertEqual(t, DetectCompression(zz.Bytes()), COMPRESSION_ZLIB)
+		AssertEqual(t, DetectCompression(bz), COMPRESSION_BZIP2)
+		AssertEqual(t, DetectCompression([]byte(joined)), COMPRESSION_NONE)
+		AssertEqual(t, DetectCompression([]byte("x^yz;1.0\n")), COMPRESSION_NONE)
+		AssertEqual(t, DetectCompression([]byte("BZh9;1.0\n")), COMPRESSION_NONE)
+		for _, text := range []string{"hCity;12.3\n", "8Oslo;-3.4\n"} { // valid zlib headers
+			AssertEqual(t, DetectCompression([]byte(strings.Repeat(text, HEADER_SIZE/len(text)+1))), COMPRESSION_NONE)
+		}
+		AssertEqual(t, DetectCompression(nil), COMPRESSION_NONE)
+	})
+
+	t.Run("gzip members are read in order", func(t *testing.T) {
+		r := newGzipMembers(bytes.NewReader(members.Bytes()), int64(members.Len()), 2)
+		data, err := io.ReadAll(r)
+		AssertTrue(t, err == nil)
+		AssertEqual(t, string(data), joined)
+	})
+
+	t.Run("gzip members are not read after Close", func(t *testing.T) {
+		r := &closedReaderAt{r: bytes.NewReader(members.Bytes())}
+		g := newGzipMembers(r, int64(members.Len()), 4)
+		_, err := g.Read(make([]byte, 1))
+		AssertTrue(t, err == nil)
+		AssertTrue(t, g.Close() == nil)
+		r.closed.Store(true)
+		AssertTrue(t, r.readAfterClose.Load() == 0)
+	})
+}
+
+// closedReaderAt counts the reads after closed is set
+type closedReaderAt struct {
+	r              io.ReaderAt
+	closed         atomic.Bool
+	readAfterClose atomic.Int64
+}
+
+func (c *closedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if c.closed.Load() {
+		c.readAfterClose.Add(1)
+	}
+	return c.r.ReadAt(p, off)
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := t.TempDir()
+	shards := map[string]string{
+		"day1.txt":       "Hamburg;12.0\nBulawayo;8.9\n",
+		"day2.txt":       "Hamburg;-2.0\n",
+		"later/day3.txt": "Bulawayo;1.1\nHamburg;4.0\n",
+	}
+	for name, content := range shards {
+		fname := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(fname), 0o755)
+		if err := os.WriteFile(fname, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("expands globs and directories", func(t *testing.T) {
+		paths, err := ExpandPaths([]string{filepath.Join(dir, "day*.txt"), dir})
+		AssertTrue(t, err == nil)
+		AssertEqual(t, len(paths), 3)
+		AssertEqual(t, paths[0], filepath.Join(dir, "day1.txt"))
+		AssertEqual(t, paths[2], filepath.Join(dir, "later", "day3.txt"))
+		paths, err = ExpandPaths([]string{dir + "/./day1.txt", filepath.Join(dir, "later", "..", "day1.txt"), dir + "/"})
+		AssertTrue(t, err == nil)
+		AssertEqual(t, len(paths), 3)
+		AssertEqual(t, paths[0], filepath.Join(dir, "day1.txt"))
+		_, err = ExpandPaths([]string{filepath.Join(dir, "missing.txt")})
+		AssertTrue(t, errors.Is(err, os.ErrNotExist))
+	})
+
+	paths, _ := ExpandPaths([]string{dir})
+	sources := make([]Source, len(paths))
+	for i, path := range paths {
+		sources[i] = FileSource(path)
+	}
+	for _, mode := range Names() {
+		p, _ := Get(mode)
+		t.Run(mode+" merges the files", func(t *testing.T) {
+			res, files, err := RunFiles(context.Background(), p, sources, Options{Workers: 2})
+			AssertTrue(t, err == nil)
+			AssertEqual(t, res.String(), "{Bulawayo=1.1/5.0/8.9, Hamburg=-2.0/4.7/12.0}")
+			AssertEqual(t, res.Lines, int64(5))
+			AssertEqual(t, len(files), 3)
+			AssertEqual(t, files[1].Result.String(), "{Hamburg=-2.0/-2.0/-2.0}")
+		})
+	}
+
+	t.Run("a failing file fails the run", func(t *testing.T) {
+		bad := StringSource("bad.txt", "Hamburg;1.0\nHamburg\n")
+		p, _ := Get(MODE_BYTES)
+		_, _, err := RunFiles(context.Background(), p, append(sources, bad), Options{Workers: 2, OnReject: domain.REJECT_FAIL})
+		var perr *domain.ParseError
+		AssertTrue(t, errors.As(err, &perr))
+		AssertEqual(t, perr.File, "bad.txt")
+	})
+
+	t.Run("files not read keep their name", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		p, _ := Get(MODE_BYTES)
+		res, files, err := RunFiles(ctx, p, sources, Options{Workers: 2})
+		AssertTrue(t, errors.Is(err, context.Canceled))
+		AssertTrue(t, res.Partial && res.Merged)
+		AssertTrue(t, !strings.Contains(res.Summary(), "offset"))
+		AssertEqual(t, files[2].Name, sources[2].Name())
+		AssertTrue(t, files[2].Result == nil)
+	})
+
+	t.Run("the reject budget applies to all files", func(t *testing.T) {
+		bad := StringSource("bad.txt", "Hamburg;1.0\nHamburg\n")
+		p, _ := Get(MODE_BYTES)
+		var quarantine bytes.Buffer
+		opts := Options{Workers: 2, MaxRejectRate: 0.3, OnReject: domain.REJECT_QUARANTINE, Quarantine: &quarantine}
+		res, _, err := RunFiles(context.Background(), p, append(sources, bad), opts)
+		AssertTrue(t, err == nil)
+		AssertEqual(t, res.Errors, int64(1))
+		AssertEqual(t, quarantine.String(), "bad.txt\t2\t12\tmissing ';' separator\tHamburg\n")
+		opts.MaxRejectRate = 0.1
+		_, _, err = RunFiles(context.Background(), p, append(sources, bad), opts)
+		AssertTrue(t, errors.Is(err, domain.ErrRejectBudget))
+	})
+}
+
+func TestStats(t *testing.T) {
+	src := StringSource("stats", "a;1.0\na;2.0\nb;-5.0\na;2.0\na;9.9\n")
+	for _, mode := range Names() {
+		p, _ := Get(mode)
+		res, err := p.Run(context.Background(), src, Options{Workers: 3, BufferSize: 16, Stats: true})
+		AssertTrue(t, err == nil)
+		t.Run(mode, func(t *testing.T) {
+			stats := res.Stations["a"].Stats
+			AssertEqual(t, stats.Count, int64(4))
+			AssertEqual(t, stats.Median(), 20)
+			AssertEqual(t, stats.Mode(), 20)
+			AssertEqual(t, stats.Percentile(90), 99)
+			AssertTrue(t, stats.Variance() > 1287 && stats.Variance() < 1288)
+			AssertEqual(t, res.Stations["b"].Stats.Count, int64(1))
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	src := StringSource("filter", "a;1.0\nb;2.0\nab;-5.0\na;40.0\nbad\nc;3.0\n")
+	filter := domain.NewFilter()
+	filter.Exclude = map[string]bool{"c": true}
+	filter.Pattern = regexp.MustCompile("^a")
+	filter.MaxTemperature = 300
+	for _, mode := range Names() {
+		p, _ := Get(mode)
+		t.Run(mode, func(t *testing.T) {
+			for run := 0; run < 2; run++ { // counts do not accumulate across runs
+				res, err := p.Run(context.Background(), src, Options{Workers: 3, BufferSize: 16, Filter: filter})
+				AssertTrue(t, err == nil)
+				AssertEqual(t, res.String(), "{a=1.0/1.0/1.0, ab=-5.0/-5.0/-5.0}")
+				AssertEqual(t, res.Filtered, int64(3))
+				AssertEqual(t, res.Errors, int64(1))
+				AssertEqual(t, res.Lines, int64(6))
+			}
+		})
+	}
+}
+
+func TestWindowed(t *testing.T) {
+	src := StringSource("windowed", "a;2024-01-01T10:15:00Z;1.0\na;1704104100;3.0\nb;2024-01-01T11:00:00+01:00;2.0\na;2.0\na;2024-01-01T11:00:00Z;-1.0\n")
+	res, err := RunWindowed(context.Background(), src, domain.WINDOW_HOUR, Options{Stats: true})
+	AssertTrue(t, err == nil)
+	AssertEqual(t, res.String(), "2024-01-01T10:00:00Z {a=1.0/2.0/3.0, b=2.0/2.0/2.0}\n2024-01-01T11:00:00Z {a=-1.0/-1.0/-1.0}\n")
+	AssertEqual(t, res.Total.String(), "{a=-1.0/1.3/3.0, b=2.0/2.0/2.0}")
+	AssertEqual(t, res.Total.Errors, int64(0))
+	AssertEqual(t, res.Total.Lines, int64(5))
+	AssertEqual(t, res.Untimed, int64(1))
+	AssertEqual(t, res.Total.Stations["a"].Stats.Count, int64(4))
+
+	bad := StringSource("bad", "a;2024-01-01T10:15:00Z;1.0\na;2024-01-01;3.0\n")
+	_, err = RunWindowed(context.Background(), bad, domain.WINDOW_DAY, Options{OnReject: domain.REJECT_FAIL})
+	AssertTrue(t, errors.Is(err, domain.ErrInvalidTimestamp))
+}
+
+func TestTimestampColumn(t *testing.T) {
+	src := StringSource("timed", "a;2024-01-01T10:15:00Z;1.0\na;3.0\nb;1704104100000;2.0\n")
+	for _, mode := range Names() {
+		p, _ := Get(mode)
+		res, err := p.Run(context.Background(), src, Options{Workers: 3, BufferSize: 16})
+		t.Run(mode, func(t *testing.T) {
+			AssertTrue(t, err == nil)
+			AssertEqual(t, res.String(), "{a=1.0/2.0/3.0, b=2.0/2.0/2.0}")
+			AssertEqual(t, res.Errors, int64(0))
+		})
+	}
+}
+
+func TestProgress(t *testing.T) {
+	content := "Hamburg;12.0\nBulawayo;8.9\n\nHamburg\nPalembang;38.8\nBulawayo;1.0\nOslo;-3.0"
+	fname := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(fname, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range Names() {
+		p, _ := Get(mode)
+		counters := &progress.Counters{}
+		res, err := p.Run(context.Background(), FileSource(fname), Options{Workers: 3, BufferSize: 16, Progress: counters})
+		t.Run(mode, func(t *testing.T) {
+			AssertTrue(t, err == nil)
+			AssertEqual(t, counters.Snapshot().Lines, res.Lines)
+			AssertEqual(t, counters.Snapshot().Bytes, int64(len(content)))
+		})
+	}
+	counters := &progress.Counters{}
+	res, err := RunWindowed(context.Background(), FileSource(fname), domain.WINDOW_DAY, Options{Progress: counters})
+	AssertTrue(t, err == nil)
+	AssertEqual(t, counters.Snapshot().Lines, res.Total.Lines)
+	AssertEqual(t, counters.Snapshot().Bytes, int64(len(content)))
+}
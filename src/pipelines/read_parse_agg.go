@@ -1,9 +1,7 @@
 package pipelines
 
 import (
-	"fmt"
-	"math"
-	"sort"
+	"context"
 	"sync"
 	"time"
 
@@ -11,17 +9,20 @@ import (
 	"github.com/brcgo/src/workers"
 )
 
-func ReadParseAggregatePipeline(fname string, NO_OF_PARSER_WORKERS, NO_OF_AGGREGATOR_WORKERS int, verbose bool) {
+// Reader sends lines to parsers, parsers shard parsed values by key to aggregators owning a set of keys
+func ReadParseAggregatePipeline(ctx context.Context, src Source, opts Options) (*domain.Result, error) {
 
 	startTime := time.Now()
 
-	if verbose {
-		fmt.Println("Setup plumbing...")
+	input, err := src.Open()
+	if err != nil {
+		return nil, err
 	}
+	defer input.Close()
 
-	lineChan := make(chan string)
-	parsedChans := make([]chan domain.StringFloat, NO_OF_AGGREGATOR_WORKERS)
-	resultChan := make(chan workers.AggregatorResult, NO_OF_AGGREGATOR_WORKERS)
+	lineChan := make(chan workers.Line)
+	parsedChans := make([]chan domain.StringFloat, opts.AggregatorWorkers)
+	resultChan := make(chan workers.AggregatorResult, opts.AggregatorWorkers)
 
 	// Create aggregator channels
 	for i := range parsedChans {
@@ -30,29 +31,21 @@ func ReadParseAggregatePipeline(fname string, NO_OF_PARSER_WORKERS, NO_OF_AGGREG
 
 	// Start aggregators
 	var wgAggregators sync.WaitGroup
-	for i := 0; i < NO_OF_AGGREGATOR_WORKERS; i++ {
+	for i := 0; i < opts.AggregatorWorkers; i++ {
 		wgAggregators.Add(1)
-		go workers.AggregatorWorker(i, parsedChans[i], resultChan, &wgAggregators)
+		go workers.AggregatorWorker(i, parsedChans[i], resultChan, opts.newStats, opts.Progress, &wgAggregators)
 	}
 
 	// Start parsers
 	var wgParsers sync.WaitGroup
-	for i := 0; i < NO_OF_PARSER_WORKERS; i++ {
+	errors := opts.errorLog()
+	for i := 0; i < opts.ParserWorkers; i++ {
 		wgParsers.Add(1)
-		go workers.ParserWorker(i, lineChan, parsedChans, NO_OF_AGGREGATOR_WORKERS, &wgParsers)
+		go workers.ParserWorker(i, lineChan, parsedChans, opts.AggregatorWorkers, rejecter(errors), opts.Filter, &wgParsers)
 	}
 
-	if verbose {
-		fmt.Println("Starting pipeline...")
-	}
-
-	// Reader
-	err := workers.GetLines(fname, lineChan)
-	if err != nil {
-		fmt.Printf("Error reading file: %v\n", err)
-	}
-
-	close(lineChan)
+	// Reader, closes lineChan when done or when ctx is cancelled, the other stages drain what was read
+	read, err := workers.GetLines(ctx, input, lineChan, opts.Progress)
 
 	wgParsers.Wait()
 	for _, ch := range parsedChans {
@@ -61,55 +54,13 @@ func ReadParseAggregatePipeline(fname string, NO_OF_PARSER_WORKERS, NO_OF_AGGREG
 
 	wgAggregators.Wait()
 	close(resultChan)
+	processed := time.Now()
 
 	// Combine results
 	finalMap := make(map[string]domain.StationData)
-	var totalStats workers.AggregatorStats
 	for res := range resultChan {
-		if verbose {
-			fmt.Printf("Aggregator %d stats: %d items, %d keys\n",
-				res.ID, res.Stats.ItemsProcessed, res.Stats.UniqueKeys)
-		}
-
-		for k, v := range res.Data {
-			value, exists := finalMap[k]
-			if !exists {
-				finalMap[k] = domain.StationData{
-					Min:   v.Min,
-					Max:   v.Max,
-					Sum:   v.Sum,
-					Count: v.Count,
-				}
-			} else {
-				finalMap[k] = domain.StationData{
-					Min:   math.Min(v.Min, value.Min),
-					Max:   math.Max(v.Max, value.Max),
-					Sum:   value.Sum + v.Sum,
-					Count: value.Count + v.Count,
-				}
-			}
-
-		}
-
-		totalStats.ItemsProcessed += res.Stats.ItemsProcessed
-		totalStats.UniqueKeys += res.Stats.UniqueKeys // may have overlap
-	}
-
-	// Sort and print final results
-	keys := make([]string, 0, len(finalMap))
-	for k := range finalMap {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	if verbose {
-		fmt.Println("\n Final aggregated results:")
-		for _, k := range keys {
-			fmt.Printf("%s=%s\n", k, finalMap[k].String())
-		}
+		domain.MergeStations(finalMap, res.Data)
 	}
 
-	elapsed := time.Since(startTime)
-	fmt.Printf("\nDone in %s. Processed %d lines, approx. %d unique keys\n",
-		elapsed, totalStats.ItemsProcessed, len(finalMap))
+	return partial(ctx, toResult(finalMap, errors, opts.Filter, read, startTime, processed), err)
 }
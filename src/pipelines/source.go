@@ -0,0 +1,243 @@
+package pipelines
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// UNKNOWN_SIZE is the size of a source that is only known after reading it
+const UNKNOWN_SIZE = -1
+
+var ErrSourceConsumed = errors.New("source can only be read once")
+
+// Source is the input of a pipeline run
+type Source interface {
+	// Name of the source in messages
+	Name() string
+	// Open returns a reader at the start of the input, the caller closes it
+	Open() (io.ReadCloser, error)
+	// Size of the input in bytes, UNKNOWN_SIZE when it is not known before reading
+	Size() (int64, error)
+}
+
+type fileSource struct {
+	path string
+}
+
+// FileSource reads the file at path, it can be opened any number of times
+func FileSource(path string) Source {
+	return fileSource{path: path}
+}
+
+func (s fileSource) Name() string {
+	return s.path
+}
+
+func (s fileSource) Open() (io.ReadCloser, error) {
+	return os.Open(s.path)
+}
+
+func (s fileSource) Size() (int64, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return UNKNOWN_SIZE, nil
+	}
+	return info.Size(), nil
+}
+
+type stdinSource struct {
+	once sync.Once
+}
+
+// StdinSource reads standard input, it can be opened once
+func StdinSource() Source {
+	return &stdinSource{}
+}
+
+func (s *stdinSource) Name() string {
+	return "stdin"
+}
+
+// Open returns os.Stdin itself, so stdin redirected from a regular file can still be mapped
+func (s *stdinSource) Open() (io.ReadCloser, error) {
+	err := ErrSourceConsumed
+	s.once.Do(func() { err = nil })
+	if err != nil {
+		return nil, err
+	}
+	return os.Stdin, nil
+}
+
+func (s *stdinSource) Size() (int64, error) {
+	return fileSource{path: os.Stdin.Name()}.Size()
+}
+
+type bytesSource struct {
+	name string
+	data []byte
+}
+
+// BytesSource reads data held in memory, it can be opened any number of times
+func BytesSource(name string, data []byte) Source {
+	return bytesSource{name: name, data: data}
+}
+
+// StringSource is BytesSource for a string
+func StringSource(name, data string) Source {
+	return bytesSource{name: name, data: []byte(data)}
+}
+
+func (s bytesSource) Name() string {
+	return s.name
+}
+
+func (s bytesSource) Open() (io.ReadCloser, error) {
+	return bytesReader{bytes.NewReader(s.data)}, nil
+}
+
+func (s bytesSource) Size() (int64, error) {
+	return int64(len(s.data)), nil
+}
+
+// bytesReader keeps ReadAt and Size of bytes.Reader visible to ProcessReader
+type bytesReader struct {
+	*bytes.Reader
+}
+
+func (bytesReader) Close() error {
+	return nil
+}
+
+type readerSource struct {
+	name string
+	r    io.Reader
+	mu   sync.Mutex
+	read bool
+}
+
+// ReaderSource reads r, which is not closed. It can be opened again only when r is an io.Seeker,
+// every Open seeks back to the start.
+func ReaderSource(name string, r io.Reader) Source {
+	return &readerSource{name: name, r: r}
+}
+
+func (s *readerSource) Name() string {
+	return s.name
+}
+
+func (s *readerSource) Open() (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if seeker, ok := s.r.(io.Seeker); ok {
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	} else if s.read {
+		return nil, ErrSourceConsumed
+	}
+	s.read = true
+	return io.NopCloser(s.r), nil
+}
+
+func (s *readerSource) Size() (int64, error) {
+	if sized, ok := s.r.(interface{ Size() int64 }); ok {
+		return sized.Size(), nil
+	}
+	return UNKNOWN_SIZE, nil
+}
+
+type multiSource struct {
+	sources []Source
+}
+
+// MultiSource reads the sources one after the other as a single input. A source that does not end
+// with a newline is followed by one, so its last line is not joined with the first line of the next.
+// Offsets and line numbers are those of the concatenated input.
+func MultiSource(sources ...Source) Source {
+	if len(sources) == 1 {
+		return sources[0]
+	}
+	return multiSource{sources: sources}
+}
+
+func (s multiSource) Name() string {
+	names := make([]string, len(s.sources))
+	for i, src := range s.sources {
+		names[i] = src.Name()
+	}
+	return strings.Join(names, ", ")
+}
+
+func (s multiSource) Open() (io.ReadCloser, error) {
+	return &multiReader{sources: s.sources}, nil
+}
+
+// Size is the sum of the sizes of the sources, without the newlines added between them
+func (s multiSource) Size() (int64, error) {
+	var total int64
+	for _, src := range s.sources {
+		size, err := src.Size()
+		if err != nil || size == UNKNOWN_SIZE {
+			return size, err
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// multiReader opens the sources one at a time while reading
+type multiReader struct {
+	sources []Source
+	current io.ReadCloser
+	last    byte // last byte read from the current source
+}
+
+func (m *multiReader) Read(p []byte) (int, error) {
+	for len(p) > 0 {
+		if m.current == nil {
+			if len(m.sources) == 0 {
+				return 0, io.EOF
+			}
+			r, err := m.sources[0].Open()
+			if err != nil {
+				return 0, err
+			}
+			m.current, m.sources, m.last = r, m.sources[1:], ASCII_NEWLINE
+		}
+		n, err := m.current.Read(p)
+		if n > 0 {
+			m.last = p[n-1]
+			return n, nil
+		}
+		if err == io.EOF {
+			err = m.current.Close()
+			m.current = nil
+			if err != nil {
+				return 0, err
+			}
+			if m.last != ASCII_NEWLINE && len(m.sources) > 0 {
+				p[0] = ASCII_NEWLINE
+				return 1, nil
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, nil
+}
+
+func (m *multiReader) Close() error {
+	if m.current == nil {
+		return nil
+	}
+	return m.current.Close()
+}
@@ -0,0 +1,122 @@
+package pipelines
+
+import (
+	"context"
+	"time"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/workers"
+)
+
+// RunWindowed aggregates the lines <station>;<timestamp>;<temperature> of src into tumbling windows,
+// see domain.WindowStart and domain.ParseTimestamp. Lines without a timestamp are only aggregated in
+// the total. The reject policy, filter and extended statistics of opts apply like in Run.
+func RunWindowed(ctx context.Context, src Source, window string, opts Options) (*domain.WindowedResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	opts = opts.forRun(cancel)
+	res, err := windowed(ctx, src, window, opts)
+	var total *domain.Result
+	if res != nil {
+		total = res.Total
+	}
+	if err := finishRun(total, opts); err != nil {
+		return nil, err
+	}
+	return res, err
+}
+
+// windowed scans src line by line, readings are added to their window and to the total
+func windowed(ctx context.Context, src Source, window string, opts Options) (*domain.WindowedResult, error) {
+	startTime := time.Now()
+
+	input, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	res := domain.NewWindowedResult(window)
+	var cnt, lineNo int64
+	errors := opts.errorLog()
+
+	// consecutive readings mostly fall in the same window
+	var start time.Time
+	var current *domain.Result
+
+	var stopped error
+	tracked := tracker{counters: opts.Progress}
+	scanner := workers.NewLineScanner(input)
+	for scanner.Scan() {
+		lineNo++
+		if lineNo%CTX_CHECK_LINES == 0 {
+			tracked.update(scanner.Offset, cnt)
+			opts.Progress.SeenStations(int64(len(res.Total.Stations)))
+			if ctx.Err() != nil {
+				stopped = ctx.Err()
+				break
+			}
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		cnt++
+		data, err := domain.ParseStringIntTime(scanner.Text())
+		if err != nil {
+			rejectLine(errors, err, scanner.Text(), lineNo, scanner.Offset)
+			continue
+		}
+		if !opts.Filter.Keep(data.Key, data.Value) {
+			continue
+		}
+		if data.Time.IsZero() {
+			res.Untimed++
+			addReading(res.Total, data.Key, data.Value, opts)
+			continue
+		}
+		if ws := domain.WindowStart(data.Time, window); current == nil || !ws.Equal(start) {
+			start, current = ws, res.Result(ws)
+		}
+		addReading(current, data.Key, data.Value, opts)
+		addReading(res.Total, data.Key, data.Value, opts)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if stopped == nil {
+		tracked.update(scanner.Next, cnt)
+	}
+
+	total := res.Total
+	total.Lines = cnt
+	total.Bytes = scanner.Next
+	total.Errors = errors.Count()
+	total.Filtered = opts.Filter.Filtered()
+	total.ParseErrors = errors.Errors()
+	total.Timings.Started = startTime
+	total.Timings.Process = time.Since(startTime)
+	total.Timings.Total = total.Timings.Process
+
+	if stopped != nil {
+		total.Bytes = scanner.Offset
+		_, err := partial(ctx, total, stopped)
+		return res, err
+	}
+	return res, nil
+}
+
+// addReading adds a reading in tenths of a degree to res, with its extended statistics when enabled
+func addReading(res *domain.Result, name string, value int, opts Options) {
+	res.Add(name, value)
+	if opts.Stats {
+		station := res.Stations[name]
+		if station.Stats == nil {
+			station.Stats = domain.NewStats()
+		}
+		station.Stats.Add(value)
+	}
+}
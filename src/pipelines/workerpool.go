@@ -1,8 +1,7 @@
 package pipelines
 
 import (
-	"fmt"
-	"sort"
+	"context"
 	"sync"
 	"time"
 
@@ -12,47 +11,54 @@ import (
 
 // Reading input and distributing it to a worker pool using goroutines and channels
 // Wokers update the same map, sharing a mutex lock
-func WorkerpoolPipeline(fname string, NO_OF_WORKERS int, verbose bool) {
+func WorkerpoolPipeline(ctx context.Context, src Source, opts Options) (*domain.Result, error) {
 
 	startTime := time.Now()
 
-	lineChan := make(chan string)
+	input, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	lineChan := make(chan workers.Line)
 	var wg sync.WaitGroup
 
 	// Shared map and mutex
 	resultMap := make(map[string]domain.StationData)
 	var mapMutex sync.Mutex
+	errors := opts.errorLog()
 
 	// Start worker pool
-	for i := 1; i <= NO_OF_WORKERS; i++ {
+	for i := 1; i <= opts.Workers; i++ {
 		wg.Add(1)
-		go workers.LineWorker(i, lineChan, &resultMap, &mapMutex, &wg)
+		go workers.LineWorker(i, lineChan, &resultMap, &mapMutex, rejecter(errors), opts.Filter, opts.newStats, opts.Progress, &wg)
 	}
 
-	// Read file and send lines to channel
-	err := workers.GetLines(fname, lineChan)
-	if err != nil {
-		fmt.Printf("Error reading file: %v\n", err)
-	}
+	// Read file and send lines to channel, GetLines closes it when done.
+	// When ctx is cancelled the workers drain the lines already read.
+	read, err := workers.GetLines(ctx, input, lineChan, opts.Progress)
+	wg.Wait() // Wait for all workers to finish
 
-	close(lineChan) // No more lines coming
-	wg.Wait()       // Wait for all workers to finish
-
-	// Sort and print final results
-	keys := make([]string, 0, len(resultMap))
-	for k := range resultMap {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
+	return partial(ctx, toResult(resultMap, errors, opts.Filter, read, startTime, time.Now()), err)
+}
 
-	if verbose {
-		fmt.Println("\n Final aggregated results:")
-		for _, k := range keys {
-			fmt.Printf("%s=%s\n", k, resultMap[k].String())
-		}
+// toResult converts float aggregates of the read bytes of a file to a Result
+func toResult(resultMap map[string]domain.StationData, errors *domain.ErrorLog, filter *domain.Filter, read int64, startTime, processed time.Time) *domain.Result {
+	result := domain.NewResult()
+	for k, v := range resultMap {
+		data := v.ToInt()
+		result.Stations[k] = &data
+		result.Lines += int64(v.Count)
 	}
-
-	elapsed := time.Since(startTime)
-	fmt.Printf("\nDone in %s. Processed %d lines, %d unique keys\n",
-		elapsed, -1, len(resultMap))
+	result.Errors = errors.Count()
+	result.ParseErrors = errors.Errors()
+	result.Filtered = filter.Filtered()
+	result.Lines += result.Errors + result.Filtered
+	result.Bytes = read
+	result.Timings.Started = startTime
+	result.Timings.Process = processed.Sub(startTime)
+	result.Timings.Merge = time.Since(processed)
+	result.Timings.Total = time.Since(startTime)
+	return result
 }
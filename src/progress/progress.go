@@ -0,0 +1,156 @@
+// Package progress reports how far a pipeline run is, rendered to a terminal at a fixed interval
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Interval between two renders of the progress line
+const INTERVAL = 500 * time.Millisecond
+
+// Counters are fed by the pipelines, safe for concurrent use. All methods accept a nil receiver,
+// so pipelines report unconditionally and a nil *Counters disables progress.
+type Counters struct {
+	bytes    atomic.Int64
+	lines    atomic.Int64
+	stations atomic.Int64
+}
+
+// Add bytes consumed and lines parsed
+func (c *Counters) Add(bytes, lines int64) {
+	if c == nil {
+		return
+	}
+	c.bytes.Add(bytes)
+	c.lines.Add(lines)
+}
+
+// AddStations counts new stations, for pipelines where every station is seen by one worker only
+func (c *Counters) AddStations(n int64) {
+	if c == nil {
+		return
+	}
+	c.stations.Add(n)
+}
+
+// SeenStations raises the station count to n, for workers that each see a subset of overlapping stations
+func (c *Counters) SeenStations(n int64) {
+	if c == nil {
+		return
+	}
+	for {
+		cur := c.stations.Load()
+		if n <= cur || c.stations.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+// Lines parsed so far
+func (c *Counters) Lines() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.lines.Load()
+}
+
+// Snapshot of the counters
+type Snapshot struct {
+	Bytes    int64
+	Lines    int64
+	Stations int64
+}
+
+func (c *Counters) Snapshot() Snapshot {
+	if c == nil {
+		return Snapshot{}
+	}
+	return Snapshot{Bytes: c.bytes.Load(), Lines: c.lines.Load(), Stations: c.stations.Load()}
+}
+
+// Reporter renders Counters to w every INTERVAL until it is stopped
+type Reporter struct {
+	Counters *Counters
+	w        io.Writer
+	total    int64 // input size in bytes, 0 when unknown
+	started  time.Time
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Start reporting the progress of a run over an input of total bytes, 0 when unknown
+func Start(w io.Writer, total int64) *Reporter {
+	r := &Reporter{
+		Counters: &Counters{},
+		w:        w,
+		total:    total,
+		started:  time.Now(),
+		stop:     make(chan struct{}),
+	}
+	r.wg.Add(1)
+	go r.loop()
+	return r
+}
+
+func (r *Reporter) loop() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fmt.Fprintf(r.w, "\r%s\033[K", Format(r.Counters.Snapshot(), r.total, time.Since(r.started)))
+		case <-r.stop:
+			fmt.Fprint(r.w, "\r\033[K") // leave a clean line for the result
+			return
+		}
+	}
+}
+
+// Stop clears the progress line, the reporter can not be restarted
+func (r *Reporter) Stop() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+// Format renders a snapshot taken elapsed after the start of a run over total bytes
+func Format(s Snapshot, total int64, elapsed time.Duration) string {
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(s.Bytes) / elapsed.Seconds()
+	}
+	line := formatBytes(s.Bytes)
+	if total > 0 {
+		line += fmt.Sprintf(" / %s (%.1f%%)", formatBytes(total), 100*float64(s.Bytes)/float64(total))
+	}
+	line += fmt.Sprintf("  %.1f MB/s  %d lines  %d stations", rate/(1024*1024), s.Lines, s.Stations)
+	if total > 0 && rate > 0 && s.Bytes < total {
+		eta := time.Duration(float64(total-s.Bytes) / rate * float64(time.Second))
+		line += fmt.Sprintf("  ETA %s", eta.Round(time.Second))
+	}
+	return line
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// IsTerminal tells if f is a character device such as a terminal, false for files and pipes
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
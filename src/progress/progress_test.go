@@ -0,0 +1,48 @@
+package progress
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+func TestCounters(t *testing.T) {
+
+	t.Run("Concurrent updates", func(t *testing.T) {
+		c := &Counters{}
+		var wg sync.WaitGroup
+		for i := 1; i <= 8; i++ {
+			wg.Add(1)
+			go func(n int64) {
+				defer wg.Done()
+				c.Add(100, 10)
+				c.SeenStations(n)
+			}(int64(i))
+		}
+		wg.Wait()
+		AssertEqual(t, c.Snapshot(), Snapshot{Bytes: 800, Lines: 80, Stations: 8})
+	})
+
+	t.Run("Nil counters are ignored", func(t *testing.T) {
+		var c *Counters
+		c.Add(1, 1)
+		c.AddStations(1)
+		c.SeenStations(1)
+		AssertEqual(t, c.Snapshot(), Snapshot{})
+	})
+}
+
+func TestFormat(t *testing.T) {
+
+	t.Run("Known size", func(t *testing.T) {
+		s := Snapshot{Bytes: 256 * 1024 * 1024, Lines: 1000, Stations: 42}
+		AssertEqual(t, Format(s, 1024*1024*1024, 2*time.Second),
+			"256.0 MB / 1.0 GB (25.0%)  128.0 MB/s  1000 lines  42 stations  ETA 6s")
+	})
+
+	t.Run("Unknown size", func(t *testing.T) {
+		AssertEqual(t, Format(Snapshot{Bytes: 512}, 0, time.Second), "512 B  0.0 MB/s  0 lines  0 stations")
+	})
+}
@@ -0,0 +1,108 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/brcgo/src/output"
+	"github.com/brcgo/src/pipelines"
+)
+
+// Sample is a measurement file with the expected output of the challenge next to it
+type Sample struct {
+	Name     string // file name without .txt
+	Input    string // path of measurements-<name>.txt
+	Expected string // path of measurements-<name>.out
+}
+
+// FindSamples returns the measurements-*.txt files of dir that have a .out file, sorted by name
+func FindSamples(dir string) ([]Sample, error) {
+	inputs, err := filepath.Glob(filepath.Join(dir, "measurements-*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(inputs)
+	samples := make([]Sample, 0, len(inputs))
+	for _, input := range inputs {
+		expected := strings.TrimSuffix(input, ".txt") + ".out"
+		if _, err := os.Stat(expected); err != nil {
+			continue
+		}
+		samples = append(samples, Sample{
+			Name:     strings.TrimSuffix(filepath.Base(input), ".txt"),
+			Input:    input,
+			Expected: expected,
+		})
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no measurements-*.txt and .out pairs in %s", dir)
+	}
+	return samples, nil
+}
+
+// SampleReport is the outcome of one pipeline on one sample
+type SampleReport struct {
+	Sample string
+	Mode   string
+	Err    error  // the pipeline failed
+	Diff   string // where the output differs from the expected output, empty when equal
+}
+
+func (r SampleReport) OK() bool {
+	return r.Err == nil && r.Diff == ""
+}
+
+// RunSamples runs the pipelines named by modes on every sample and compares
+// their output in the challenge format byte for byte with the expected output
+func RunSamples(ctx context.Context, samples []Sample, modes []string, opts pipelines.Options) ([]SampleReport, error) {
+	reports := make([]SampleReport, 0, len(samples)*len(modes))
+	for _, sample := range samples {
+		want, err := os.ReadFile(sample.Expected)
+		if err != nil {
+			return nil, err
+		}
+		for _, mode := range modes {
+			p, ok := pipelines.Get(mode)
+			if !ok {
+				return nil, fmt.Errorf("unknown mode %q", mode)
+			}
+			report := SampleReport{Sample: sample.Name, Mode: mode}
+			res, err := run(ctx, p, pipelines.FileSource(sample.Input), opts)
+			if err != nil {
+				report.Err = err
+			} else {
+				var got bytes.Buffer
+				if err := output.WriteChallenge(&got, res); err != nil {
+					return nil, err
+				}
+				report.Diff = Diff(want, got.Bytes())
+			}
+			reports = append(reports, report)
+		}
+	}
+	return reports, nil
+}
+
+// DIFF_CONTEXT is the number of bytes shown around the first difference
+const DIFF_CONTEXT = 30
+
+// Diff describes the first byte where got differs from want, empty when they are equal
+func Diff(want, got []byte) string {
+	if bytes.Equal(want, got) {
+		return ""
+	}
+	i := 0
+	for i < len(want) && i < len(got) && want[i] == got[i] {
+		i++
+	}
+	excerpt := func(b []byte) string {
+		start, end := max(0, i-DIFF_CONTEXT), min(len(b), i+DIFF_CONTEXT)
+		return fmt.Sprintf("%q", b[start:end])
+	}
+	return fmt.Sprintf("byte %d: want %s, got %s", i, excerpt(want), excerpt(got))
+}
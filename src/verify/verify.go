@@ -0,0 +1,203 @@
+// Package verify runs pipelines on the same input and compares their
+// canonicalized results with a straightforward reference implementation.
+package verify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/pipelines"
+)
+
+// Station is the canonical form of a station aggregate, temperatures in tenths of a degree.
+// Sums are not compared since float pipelines only approximate them, the rounded mean is.
+type Station struct {
+	Min   int
+	Mean  int
+	Max   int
+	Count int
+}
+
+func (s Station) String() string {
+	return fmt.Sprintf("%s (%d)", domain.FormatStation(s.Min, s.Mean, s.Max), s.Count)
+}
+
+func Canonicalize(res *domain.Result) map[string]Station {
+	stations := make(map[string]Station, len(res.Stations))
+	for k, v := range res.Stations {
+		stations[k] = Station{
+			Min:   v.Min,
+			Mean:  v.Mean(),
+			Max:   v.Max,
+			Count: v.Count,
+		}
+	}
+	return stations
+}
+
+// Mismatch between the reference and a pipeline for one station
+type Mismatch struct {
+	Station string
+	Field   string // min, mean, max, count, missing or unexpected
+	Want    string
+	Got     string
+}
+
+func (m Mismatch) String() string {
+	switch m.Field {
+	case "missing":
+		return fmt.Sprintf("%s: missing, want %s", m.Station, m.Want)
+	case "unexpected":
+		return fmt.Sprintf("%s: unexpected, got %s", m.Station, m.Got)
+	}
+	return fmt.Sprintf("%s: %s want %s, got %s", m.Station, m.Field, m.Want, m.Got)
+}
+
+// Compare returns the mismatches of got against want sorted by station
+func Compare(want, got *domain.Result) []Mismatch {
+	w := Canonicalize(want)
+	g := Canonicalize(got)
+
+	var mismatches []Mismatch
+	for k, ws := range w {
+		gs, exists := g[k]
+		if !exists {
+			mismatches = append(mismatches, Mismatch{Station: k, Field: "missing", Want: ws.String()})
+			continue
+		}
+		fields := []struct {
+			name      string
+			want, got int
+		}{
+			{"min", ws.Min, gs.Min},
+			{"mean", ws.Mean, gs.Mean},
+			{"max", ws.Max, gs.Max},
+			{"count", ws.Count, gs.Count},
+		}
+		for _, f := range fields {
+			if f.want != f.got {
+				mismatches = append(mismatches, Mismatch{Station: k, Field: f.name, Want: strconv.Itoa(f.want), Got: strconv.Itoa(f.got)})
+			}
+		}
+	}
+	for k, gs := range g {
+		if _, exists := w[k]; !exists {
+			mismatches = append(mismatches, Mismatch{Station: k, Field: "unexpected", Got: gs.String()})
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		if mismatches[i].Station != mismatches[j].Station {
+			return mismatches[i].Station < mismatches[j].Station
+		}
+		return mismatches[i].Field < mismatches[j].Field
+	})
+	return mismatches
+}
+
+// validTemperature is the accepted temperature format, -99.9..99.9 with exactly one decimal
+var validTemperature = regexp.MustCompile(`^-?[0-9]{1,2}\.[0-9]$`)
+
+// validTimestamp reports whether the optional timestamp column is RFC3339 or epoch seconds or
+// milliseconds, its value is not used
+func validTimestamp(s string) bool {
+	if epoch, err := strconv.ParseInt(s, 10, 64); err == nil && !strings.HasPrefix(s, "+") {
+		return epoch > -domain.MAX_EPOCH_MILLIS && epoch < domain.MAX_EPOCH_MILLIS
+	}
+	_, err := time.Parse(time.RFC3339, s)
+	return err == nil
+}
+
+// Reference aggregates src line by line with the standard library only
+func Reference(ctx context.Context, src pipelines.Source) (*domain.Result, error) {
+	input, err := src.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	res := domain.NewResult()
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		res.Lines++
+		// <station>;<temperature> or <station>;<timestamp>;<temperature>
+		name, rest, found := strings.Cut(line, ";")
+		temp := rest
+		if i := strings.LastIndex(rest, ";"); i >= 0 {
+			if !validTimestamp(rest[:i]) {
+				res.Errors++
+				continue
+			}
+			temp = rest[i+1:]
+		}
+		if !found || name == "" || !validTemperature.MatchString(temp) {
+			res.Errors++
+			continue
+		}
+		value, err := strconv.ParseFloat(temp, 64)
+		if err != nil {
+			res.Errors++
+			continue
+		}
+		res.Add(name, int(math.Round(value*10)))
+	}
+	return res, scanner.Err()
+}
+
+// Report of one pipeline
+type Report struct {
+	Mode       string
+	Err        error
+	Mismatches []Mismatch
+}
+
+func (r Report) OK() bool {
+	return r.Err == nil && len(r.Mismatches) == 0
+}
+
+// Verify runs the pipelines named by modes on src and compares each with the reference
+func Verify(ctx context.Context, src pipelines.Source, modes []string, opts pipelines.Options) ([]Report, error) {
+	want, err := Reference(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("reference: %w", err)
+	}
+
+	reports := make([]Report, 0, len(modes))
+	for _, mode := range modes {
+		p, ok := pipelines.Get(mode)
+		if !ok {
+			return nil, fmt.Errorf("unknown mode %q", mode)
+		}
+		report := Report{Mode: mode}
+		got, err := run(ctx, p, src, opts)
+		if err != nil {
+			report.Err = err
+		} else {
+			report.Mismatches = Compare(want, got)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// run turns a panicking pipeline into an error so the remaining pipelines are still verified
+func run(ctx context.Context, p pipelines.Pipeline, src pipelines.Source, opts pipelines.Options) (res *domain.Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return p.Run(ctx, src, opts)
+}
@@ -0,0 +1,161 @@
+package verify
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/pipelines"
+	"github.com/brcgo/src/util"
+	. "github.com/jnsoft/jngo/testhelper"
+)
+
+const measurements = `Hamburg;12.0
+Bulawayo;8.9
+Palembang;38.8
+St. John's;15.2
+Cracow;12.6
+Bridgetown;26.9
+Istanbul;6.2
+Roseau;34.4
+Conakry;31.2
+Istanbul;23.0
+Hamburg;-0.1
+Abéché;-99.9
+Abéché;99.9
+Abéché;0.0
+Las Palmas de Gran Canaria;-5.5
+Hamburg;-12.3
+`
+
+func writeFile(t *testing.T, content string) pipelines.Source {
+	t.Helper()
+	fname := filepath.Join(t.TempDir(), "measurements.txt")
+	if err := os.WriteFile(fname, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return pipelines.FileSource(fname)
+}
+
+func TestReference(t *testing.T) {
+	res, err := Reference(context.Background(), writeFile(t, measurements))
+	AssertTrue(t, err == nil)
+	AssertEqual(t, res.Lines, int64(16))
+	AssertEqual(t, res.NoOfStations(), 11)
+
+	hamburg := Canonicalize(res)["Hamburg"]
+	AssertEqual(t, hamburg, Station{Min: -123, Mean: -1, Max: 120, Count: 3})
+}
+
+func TestCompare(t *testing.T) {
+	want := domain.NewResult()
+	want.Add("A", 10)
+	want.Add("B", 20)
+	got := domain.NewResult()
+	got.Add("A", 11)
+	got.Add("C", 30)
+
+	mismatches := Compare(want, got)
+	AssertEqual(t, len(mismatches), 5)
+	AssertEqual(t, mismatches[0], Mismatch{Station: "A", Field: "max", Want: "10", Got: "11"})
+	AssertEqual(t, mismatches[3].Field, "missing")
+	AssertEqual(t, mismatches[4].Field, "unexpected")
+}
+
+func TestAllPipelinesAgree(t *testing.T) {
+	generated := filepath.Join(t.TempDir(), "generated.txt")
+	if err := util.GenerateFile(20000, 100, generated); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := map[string]pipelines.Source{
+		"fixed":     writeFile(t, measurements),
+		"generated": pipelines.FileSource(generated),
+		"timestamped": writeFile(t, "Hamburg;2024-05-01T13:45:00Z;12.0\nHamburg;1714563900;-3.4\n"+
+			"Oslo;1714563900123;1.0\nOslo;yesterday;2.0\nOslo;;3.0\n;1714563900;1.0\nOslo;1.5\n"),
+	}
+	opts := pipelines.Options{Workers: 4, BufferSize: 64}
+
+	for name, src := range sources {
+		reports, err := Verify(context.Background(), src, pipelines.Names(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range reports {
+			if r.Err != nil {
+				t.Errorf("%s/%s: %v", name, r.Mode, r.Err)
+			}
+			for _, m := range r.Mismatches {
+				t.Errorf("%s/%s: %s", name, r.Mode, m)
+			}
+		}
+	}
+}
+
+func TestPartialResults(t *testing.T) {
+	generated := filepath.Join(t.TempDir(), "generated.txt")
+	if err := util.GenerateFile(500000, 100, generated); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(generated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := pipelines.Options{Workers: 1, BufferSize: 4096}
+
+	for _, mode := range pipelines.Names() {
+		p, _ := pipelines.Get(mode)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		res, err := p.Run(ctx, pipelines.FileSource(generated), opts)
+		cancel()
+		if err == nil {
+			continue // finished before the timeout
+		}
+		if !errors.Is(err, context.DeadlineExceeded) || res == nil || !res.Partial {
+			t.Errorf("%s: expected a partial result, got %v", mode, err)
+			continue
+		}
+
+		t.Logf("%s: partial up to offset %d of %d", mode, res.Offset, len(data))
+
+		// a partial result is the complete result of the input before its offset
+		AssertEqual(t, res.Offset, res.Bytes)
+		want, err := Reference(context.Background(), writeFile(t, string(data[:res.Offset])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range Compare(want, res) {
+			t.Errorf("%s: %s", mode, m)
+		}
+		AssertEqual(t, res.Lines, want.Lines)
+	}
+}
+
+func TestSamples(t *testing.T) {
+	samples, err := FindSamples(filepath.Join("testdata", "samples"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports, err := RunSamples(context.Background(), samples, pipelines.Names(), pipelines.Options{Workers: 4, BufferSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertEqual(t, len(reports), len(samples)*len(pipelines.Names()))
+	for _, r := range reports {
+		if !r.OK() {
+			t.Errorf("%s %s: %v %s", r.Sample, r.Mode, r.Err, r.Diff)
+		}
+	}
+
+	t.Run("Diff", func(t *testing.T) {
+		AssertEqual(t, Diff([]byte("{a=1.0/1.0/1.0}\n"), []byte("{a=1.0/1.0/1.0}\n")), "")
+		AssertEqual(t, Diff([]byte("{a=1.0/1.5/2.0}\n"), []byte("{a=1.0/1.4/2.0}\n")),
+			`byte 9: want "{a=1.0/1.5/2.0}\n", got "{a=1.0/1.4/2.0}\n"`)
+		AssertEqual(t, Diff([]byte("{a=1.0/1.0/1.0}\n"), []byte("{a=1.0/1.0/1.0}")),
+			`byte 15: want "{a=1.0/1.0/1.0}\n", got "{a=1.0/1.0/1.0}"`)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/progress"
 )
 
 type AggregatorStats struct {
@@ -18,7 +19,7 @@ type AggregatorResult struct {
 	Stats AggregatorStats
 }
 
-func AggregatorWorker(id int, input <-chan domain.StringFloat, out chan<- AggregatorResult, wg *sync.WaitGroup) {
+func AggregatorWorker(id int, input <-chan domain.StringFloat, out chan<- AggregatorResult, newStats func() *domain.Stats, counters *progress.Counters, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	hashmap := make(map[string]domain.StationData)
@@ -27,11 +28,14 @@ func AggregatorWorker(id int, input <-chan domain.StringFloat, out chan<- Aggreg
 	for data := range input {
 		aggregated, exists := hashmap[data.Key]
 		if !exists {
+			// each aggregator owns its keys, so new keys are new stations
+			counters.AddStations(1)
 			hashmap[data.Key] = domain.StationData{
 				Min:   data.Value,
 				Max:   data.Value,
 				Sum:   data.Value,
 				Count: 1,
+				Stats: newStats(),
 			}
 		} else {
 			hashmap[data.Key] = domain.StationData{
@@ -39,8 +43,12 @@ func AggregatorWorker(id int, input <-chan domain.StringFloat, out chan<- Aggreg
 				Max:   math.Max(data.Value, aggregated.Max),
 				Sum:   data.Value + aggregated.Sum,
 				Count: aggregated.Count + 1,
+				Stats: aggregated.Stats,
 			}
 		}
+		if s := hashmap[data.Key].Stats; s != nil {
+			s.AddFloat(data.Value)
+		}
 		stats.ItemsProcessed++
 	}
 
@@ -1,29 +1,24 @@
 package workers
 
-import (
-	"fmt"
-	"os"
-)
-
-func ParseLines[T any](in <-chan string, out chan<- T, parser func(string) (T, error)) {
+func ParseLines[T any](in <-chan Line, out chan<- T, parser func(string) (T, error), onError func(Line, error)) {
 	for line := range in {
-		parsed, err := parser(line)
+		parsed, err := parser(line.Text)
 		if err == nil {
 			out <- parsed
 		} else {
-			fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
+			onError(line, err)
 		}
 	}
 	close(out)
 }
 
-func ParseLByteines[T any](in <-chan []byte, out chan<- T, parser func([]byte) (T, error)) {
+func ParseLByteines[T any](in <-chan []byte, out chan<- T, parser func([]byte) (T, error), onError func([]byte, error)) {
 	for line := range in {
 		parsed, err := parser(line)
 		if err == nil {
 			out <- parsed
 		} else {
-			fmt.Fprintf(os.Stderr, "Parse error: %v\n", err)
+			onError(line, err)
 		}
 	}
 	close(out)
@@ -2,37 +2,79 @@ package workers
 
 import (
 	"bufio"
-	"os"
+	"context"
+	"io"
+
+	"github.com/brcgo/src/progress"
 )
 
-func GetLines(filePath string, out chan<- string) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		close(out)
-		return err
-	}
-	defer file.Close()
+// Lines between cancellation checks of the line readers
+const CTX_CHECK_LINES = 1 << 16
+
+// LineScanner is a line scanner that keeps track of the offset of the current line
+type LineScanner struct {
+	*bufio.Scanner
+	Offset int64 // of the current line
+	Next   int64 // of the next line, the bytes read after the last line
+}
+
+func NewLineScanner(r io.Reader) *LineScanner {
+	s := &LineScanner{Scanner: bufio.NewScanner(r)}
+	s.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if token != nil {
+			s.Offset = s.Next
+		}
+		s.Next += int64(advance)
+		return advance, token, err
+	})
+	return s
+}
+
+// Line of the input with its position, to report it when it fails to parse
+type Line struct {
+	Text   string
+	Line   int64 // line number, from 1
+	Offset int64 // byte offset
+}
+
+// GetLines sends the non-empty lines of r to out and closes it, reporting to counters when not nil.
+// Returns the offset reached, stopping early with the error of ctx when it is cancelled.
+func GetLines(ctx context.Context, r io.Reader, out chan<- Line, counters *progress.Counters) (int64, error) {
 	defer close(out)
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		out <- scanner.Text()
+	var lines, reportedBytes, reportedLines int64
+	report := func(offset int64) {
+		counters.Add(offset-reportedBytes, lines-reportedLines)
+		reportedBytes, reportedLines = offset, lines
 	}
-	return scanner.Err()
-}
 
-func GetByteLines(filePath string, out chan<- string) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		close(out)
-		return err
+	scanner := NewLineScanner(r)
+	for scanned := 1; scanner.Scan(); scanned++ {
+		if scanned%CTX_CHECK_LINES == 0 {
+			report(scanner.Offset)
+			if ctx.Err() != nil {
+				return scanner.Offset, ctx.Err()
+			}
+		}
+		if len(scanner.Bytes()) > 0 {
+			out <- Line{Text: scanner.Text(), Line: int64(scanned), Offset: scanner.Offset}
+			lines++
+		}
 	}
-	defer file.Close()
+	report(scanner.Next)
+	return scanner.Next, scanner.Err()
+}
+
+func GetByteLines(ctx context.Context, r io.Reader, out chan<- string) (int64, error) {
+	defer close(out)
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
+	scanner := NewLineScanner(r)
+	for lines := 1; scanner.Scan(); lines++ {
+		if lines%CTX_CHECK_LINES == 0 && ctx.Err() != nil {
+			return scanner.Offset, ctx.Err()
+		}
 		out <- scanner.Text()
 	}
-	close(out)
-	return scanner.Err()
+	return scanner.Next, scanner.Err()
 }
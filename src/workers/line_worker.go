@@ -5,23 +5,33 @@ import (
 	"sync"
 
 	"github.com/brcgo/src/domain"
+	"github.com/brcgo/src/progress"
 )
 
-func LineWorker(id int, lines <-chan string, hashmap *map[string]domain.StationData, mapMutex *sync.Mutex, wg *sync.WaitGroup) {
+func LineWorker(id int, lines <-chan Line, hashmap *map[string]domain.StationData, mapMutex *sync.Mutex, onError func(Line, error), filter *domain.Filter, newStats func() *domain.Stats, counters *progress.Counters, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	for line := range lines {
-		data, _ := domain.ParseStringFloat(line)
+		data, err := domain.ParseStringFloat(line.Text)
+		if err != nil {
+			onError(line, err)
+			continue
+		}
+		if !filter.KeepFloat(data.Key, data.Value) {
+			continue
+		}
 
 		mapMutex.Lock()
 
 		aggregated, exists := (*hashmap)[data.Key]
 		if !exists {
+			counters.AddStations(1)
 			(*hashmap)[data.Key] = domain.StationData{
 				Min:   data.Value,
 				Max:   data.Value,
 				Sum:   data.Value,
 				Count: 1,
+				Stats: newStats(),
 			}
 		} else {
 			(*hashmap)[data.Key] = domain.StationData{
@@ -29,8 +39,12 @@ func LineWorker(id int, lines <-chan string, hashmap *map[string]domain.StationD
 				Max:   math.Max(data.Value, aggregated.Max),
 				Sum:   data.Value + aggregated.Sum,
 				Count: aggregated.Count + 1,
+				Stats: aggregated.Stats,
 			}
 		}
+		if s := (*hashmap)[data.Key].Stats; s != nil {
+			s.AddFloat(data.Value)
+		}
 
 		mapMutex.Unlock()
 	}
@@ -7,10 +7,17 @@ import (
 	"github.com/jnsoft/jngo/misc"
 )
 
-func ParserWorker(id int, lines <-chan string, parsedChans []chan domain.StringFloat, shardCount int, wg *sync.WaitGroup) {
+func ParserWorker(id int, lines <-chan Line, parsedChans []chan domain.StringFloat, shardCount int, onError func(Line, error), filter *domain.Filter, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for line := range lines {
-		data, _ := domain.ParseStringFloat(line)
+		data, err := domain.ParseStringFloat(line.Text)
+		if err != nil {
+			onError(line, err)
+			continue
+		}
+		if !filter.KeepFloat(data.Key, data.Value) {
+			continue
+		}
 		shard := misc.HashKey(data.Key) % shardCount
 		parsedChans[shard] <- data
 	}